@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AuditSink receives a record of every migration Install applies or fails to
+// apply, for delivery to an external audit trail. Records are delivered after
+// the migration transaction has finished, never while it holds its locks, and
+// a migration is only reported as applied once it has been committed.
+type AuditSink interface {
+	RecordApplied(ctx context.Context, version int, name string, duration time.Duration) error
+	RecordFailed(ctx context.Context, version int, name string, er error) error
+}
+
+// WithAuditSink sets the AuditSink that Install reports to. If the sink returns
+// an error for a successful install, Install returns it wrapped in a
+// PostCommitError, as the migrations themselves have been committed.
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *Schema) {
+		s.auditSink = sink
+	}
+}
+
+// audit reports the committed migrations in res, and the failing migration if
+// er is a MigrationError, to the configured AuditSink.
+func (s *Schema) audit(ctx context.Context, res *Result, er error) error {
+	if s.auditSink == nil {
+		return nil
+	}
+
+	for _, a := range res.Applied[:res.committed] {
+		if er := s.auditSink.RecordApplied(ctx, a.Version, a.Name, a.Duration); er != nil {
+			return &PostCommitError{Err: er}
+		}
+	}
+
+	var failed *MigrationError
+	if errors.As(er, &failed) {
+		if er := s.auditSink.RecordFailed(ctx, failed.Version, failed.Name, failed.Err); er != nil {
+			return &PostCommitError{Err: er}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordingSink is an AuditSink that records the versions it is told about.
+type recordingSink struct {
+	applied, failed []int
+	er              error
+}
+
+func (r *recordingSink) RecordApplied(_ context.Context, version int, _ string, _ time.Duration) error {
+	r.applied = append(r.applied, version)
+	return r.er
+}
+
+func (r *recordingSink) RecordFailed(_ context.Context, version int, _ string, _ error) error {
+	r.failed = append(r.failed, version)
+	return r.er
+}
+
+func TestAuditSink(t *testing.T) {
+	db := openDB(t)
+	sink := &recordingSink{}
+	var s Schema
+	s.Configure(WithAuditSink(sink), WithTxMode(TxPerMigration))
+	s.UpdateSQL(1, "CREATE TABLE au1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE nope nope")
+	if er := s.Install(db, 2); er == nil {
+		t.Fatal("expected failure")
+	}
+	if len(sink.applied) != 1 || sink.applied[0] != 1 || len(sink.failed) != 1 || sink.failed[0] != 2 {
+		t.Fatal(sink)
+	}
+
+	db = openDB(t)
+	boom := errors.New("boom")
+	sink = &recordingSink{er: boom}
+	var ok Schema
+	ok.Configure(WithAuditSink(sink))
+	ok.UpdateSQL(1, "CREATE TABLE au1(x INT)")
+	er := ok.Install(db, 1)
+	var pe *PostCommitError
+	if !errors.As(er, &pe) || !errors.Is(er, boom) {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 1 {
+		t.Fatal(v)
+	}
+}
@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Baseline adopts a database whose schema predates migrate: it is stamped with
+// version without running any migration, so that the next Install carries on
+// with the migrations above version. The version table is created if needed;
+// AdoptFrom, DetectExistingVersion and WithFreshCheck aren't consulted, as
+// version says where the database stands. A database that already has a
+// version other than 0 is refused, since Baseline would otherwise silently
+// skip or repeat migrations; use ForceClean to override a version on purpose.
+//
+// Check the schema against the migrations first with VerifyBaseline. For
+// fresh databases, register a Checkpoint at the same version, so that they
+// build the schema in one step rather than by replaying the history the
+// baselined databases never ran.
+func (s *Schema) Baseline(db *sql.DB, version int) error {
+	if version <= 0 {
+		return fmt.Errorf("migrate: invalid baseline version %d", version)
+	}
+
+	migrations, er := s.collect()
+	if er != nil {
+		return er
+	}
+
+	if er := s.checkMaxVersion(migrations, version); er != nil {
+		return er
+	}
+
+	baseline := s.forDB(db).clone()
+	baseline.adoptTable, baseline.detectVersion, baseline.isFresh = "", nil, nil
+	return baseline.baseline(db, migrations, version)
+}
+
+func (s *Schema) baseline(db *sql.DB, migrations []migration, version int) error {
+	ctx := context.Background()
+
+	return s.session(ctx, db, func(conn DB) error {
+		return s.transact(ctx, conn, func(tx *sql.Tx, current int) error {
+			if current != 0 {
+				return fmt.Errorf("migrate: can't baseline a database already at version %d", current)
+			}
+
+			return s.setInstalledVersion(ctx, tx, migrations, current, version)
+		})
+	})
+}
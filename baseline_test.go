@@ -0,0 +1,26 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE legacy(x INT)")
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithFreshCheck(func(*sql.DB) (bool, error) { return false, nil }))
+	s.UpdateSQL(1, "CREATE TABLE legacy(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE bl2(x INT)")
+	if er := s.Baseline(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Baseline(db, 1); er == nil {
+		t.Fatal("rebaselined")
+	}
+	res, er := s.InstallResult(db, 2)
+	if er != nil || len(res.Applied) != 1 || res.From != 1 {
+		t.Fatal(er, res)
+	}
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrVersionCapExceeded is returned when WithVersionCap is enabled and Install
+// would take the database past the version in the max_allowed column.
+var ErrVersionCapExceeded = errors.New("migrate: version cap exceeded")
+
+// WithVersionCap gives operators a database-side limit on the version the
+// application may migrate to. A nullable `max_allowed` INT column is added to
+// the version table if needed; when it is set, Install (as well as Step and
+// InstallResumable) refuses to stamp a version above it, returning
+// ErrVersionCapExceeded before running any migration that would. The cap is
+// read within the migration transaction. This option can't be combined with
+// WithVersionLog.
+func WithVersionCap() Option {
+	return func(s *Schema) {
+		s.versionCap = true
+	}
+}
+
+// checkCap returns ErrVersionCapExceeded if version caps are enabled and
+// target is above the cap.
+func (s *Schema) checkCap(ctx context.Context, q Querier, target int) error {
+	if !s.versionCap {
+		return nil
+	}
+
+	var cap sql.NullInt64
+	er := q.QueryRowContext(ctx, "SELECT max_allowed FROM "+versionTable).Scan(&cap)
+	if er == sql.ErrNoRows {
+		return nil
+	}
+
+	if er != nil {
+		return er
+	}
+
+	if cap.Valid && int64(target) > cap.Int64 {
+		return fmt.Errorf("%w: version %d is above max_allowed %d", ErrVersionCapExceeded, target, cap.Int64)
+	}
+
+	return nil
+}
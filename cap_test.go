@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestVersionCap(t *testing.T) {
+	for name, install := range map[string]func(*Schema, *sql.DB) error{
+		"Install": func(s *Schema, db *sql.DB) error { return s.Install(db, 2) },
+		"Step": func(s *Schema, db *sql.DB) error {
+			_, _, er := s.Step(db)
+			return er
+		},
+		"InstallResumable": func(s *Schema, db *sql.DB) error { return s.InstallResumable(db, 2) },
+		"InstallStepwise": func(s *Schema, db *sql.DB) error {
+			_, er := s.InstallStepwise(db, 2)
+			return er
+		},
+	} {
+		db := openDB(t)
+		var s Schema
+		s.Configure(WithVersionCap())
+		s.UpdateSQL(1, "CREATE TABLE vc1(x INT)")
+		if er := s.Install(db, 1); er != nil {
+			t.Fatal(name, er)
+		}
+		db.Exec("UPDATE version SET max_allowed = 1")
+
+		s.UpdateSQL(2, "CREATE TABLE vc2(x INT)")
+		if er := install(&s, db); !errors.Is(er, ErrVersionCapExceeded) {
+			t.Fatal(name, er)
+		}
+		if v, _ := Version(db); v != 1 {
+			t.Fatal(name, v)
+		}
+		if _, er := db.Exec("SELECT * FROM vc2"); er == nil {
+			t.Fatal(name, "vc2 exists")
+		}
+
+		db.Exec("UPDATE version SET max_allowed = NULL")
+		if er := install(&s, db); er != nil {
+			t.Fatal(name, er)
+		}
+		if v, _ := Version(db); v != 2 {
+			t.Fatal(name, v)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Checkpoint registers a closure that builds the schema as of version in one
+// step, squashing every migration registered with a minVersion no greater than
+// version. On a fresh database (version 0) Install runs only the checkpoint,
+// treats the database as being at version, and carries on with the newer
+// migrations. Databases that are already past the checkpoint never run it, and
+// skip the superseded migrations as they always would; databases part-way
+// through still run the superseded migrations they are missing, so those may
+// be kept registered for as long as such databases exist, but the last of
+// them must be registered with version itself; Validate and Install reject the
+// checkpoint otherwise. A later call replaces any earlier checkpoint. Existing
+// databases whose schema predates migrate can be brought to the checkpoint
+// with Baseline.
+func (s *Schema) Checkpoint(version int, f func(int, *sql.Tx) error) {
+	s.checkpoint = &migration{
+		minVersion: version,
+		up: func(_ context.Context, _ *Schema, version int, tx *sql.Tx) (int64, error) {
+			return -1, f(version, tx)
+		},
+	}
+}
+
+// validateCheckpoint checks that the superseded migrations still registered end
+// exactly at the checkpoint's version, so that stamping a fresh database with
+// it neither skips nor repeats anything.
+func (s *Schema) validateCheckpoint(migrations []migration) error {
+	if s.checkpoint == nil {
+		return nil
+	}
+
+	version := s.checkpoint.minVersion
+	if version <= 0 {
+		return fmt.Errorf("migrate: invalid checkpoint version %d", version)
+	}
+
+	highest := 0
+	for _, m := range migrations {
+		if m.minVersion <= version && m.minVersion > highest {
+			highest = m.minVersion
+		}
+	}
+
+	if highest != 0 && highest != version {
+		return fmt.Errorf("migrate: checkpoint at version %d, but the migrations it supersedes end at version %d", version, highest)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	var s Schema
+	s.Checkpoint(50, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE cp(x INT, y INT)"); return er })
+
+	db := openDB(t)
+	if er := s.Install(db, 50); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 50 {
+		t.Fatal(v)
+	}
+	if er := s.AssertLatest(50); er != nil {
+		t.Fatal(er)
+	}
+
+	s.UpdateSQL(60, "CREATE TABLE cp2(x INT)")
+	db = openDB(t)
+	res, er := s.InstallResult(db, 50)
+	if er != nil || res.To != 50 || len(res.Applied) != 1 {
+		t.Fatal(res, er)
+	}
+	if er := s.Install(db, 60); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 60 {
+		t.Fatal(v)
+	}
+}
+
+func TestCheckpointSuperseded(t *testing.T) {
+	var old Schema
+	old.UpdateSQL(10, "CREATE TABLE cp(x INT)")
+	db := openDB(t)
+	if er := old.Install(db, 10); er != nil {
+		t.Fatal(er)
+	}
+
+	var s Schema
+	s.UpdateSQL(10, "CREATE TABLE cp(x INT)")
+	s.UpdateSQL(50, "ALTER TABLE cp ADD y INT")
+	s.Checkpoint(50, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE cp(x INT, y INT)"); return er })
+	s.UpdateSQL(60, "CREATE TABLE cp2(x INT)")
+	if er := s.Install(db, 60); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT y FROM cp"); er != nil {
+		t.Fatal(er)
+	}
+
+	fresh := openDB(t)
+	if er := s.Install(fresh, 60); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := fresh.Exec("SELECT y FROM cp"); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestCheckpointMismatch(t *testing.T) {
+	var s Schema
+	s.UpdateSQL(10, "CREATE TABLE cm(x INT)")
+	s.UpdateSQL(40, "ALTER TABLE cm ADD y INT")
+	s.Checkpoint(50, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE cm(x INT, y INT)"); return er })
+	s.UpdateSQL(60, "CREATE TABLE cm2(x INT)")
+	db := openDB(t)
+	if er := s.Install(db, 60); er == nil {
+		t.Fatal("checkpoint past its superseded migrations accepted")
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+}
@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ChecksumMismatchError is returned by Install when WithChecksums is enabled
+// and a migration that has already been applied no longer matches the
+// checksum recorded for it in the history table, i.e. it was edited after
+// reaching the database.
+type ChecksumMismatchError struct {
+	Version  int
+	Name     string
+	Recorded string
+	Current  string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("migrate: migration %d (%s) was modified after being applied: checksum %s, recorded %s", e.Version, e.Name, e.Current, e.Recorded)
+}
+
+// WithChecksums makes Install verify the migrations already applied to the
+// database before migrating it, and implies WithHistory. The history table's
+// checksum column records each migration's Migration.Hash as it is applied;
+// if a registered migration with the same version and name now hashes
+// differently, Install fails with a ChecksumMismatchError. As closures can't
+// be hashed, only the SQL of SQL migrations (those registered with UpdateSQL,
+// loaded by AddDir or supplied by a source) is protected; renaming a closure
+// goes unnoticed, as it no longer matches its history row. History rows
+// without a checksum, such as those written by an older version of migrate,
+// aren't checked.
+func WithChecksums() Option {
+	return func(s *Schema) {
+		s.history = true
+		s.checksums = true
+	}
+}
+
+// hash returns m's Migration.Hash, or for a file AddDir streams, the hash of
+// its contents when AddDir read it.
+func (m migration) hash() string {
+	if m.digest != "" {
+		return m.digest
+	}
+
+	return Migration{Version: m.minVersion, Name: m.name, SQL: m.sql}.Hash()
+}
+
+// checkChecksums returns a ChecksumMismatchError if checksums are enabled and
+// an applied migration no longer matches its recorded checksum.
+func (s *Schema) checkChecksums(ctx context.Context, q Querier, migrations []migration) error {
+	if !s.checksums {
+		return nil
+	}
+
+	type key struct {
+		version int
+		name    string
+	}
+
+	current := make(map[key]string)
+	for _, m := range migrations {
+		current[key{m.minVersion, m.name}] = m.hash()
+	}
+
+	rows, er := q.QueryContext(ctx, "SELECT version, name, checksum FROM "+s.historyTable()+" WHERE checksum IS NOT NULL AND "+notReverted+" ORDER BY version")
+	if er != nil {
+		return er
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var name string
+		var recorded sql.NullString
+		if er := rows.Scan(&version, &name, &recorded); er != nil {
+			return er
+		}
+
+		if hash, ok := current[key{version, name}]; ok && hash != recorded.String {
+			return &ChecksumMismatchError{Version: version, Name: name, Recorded: recorded.String, Current: hash}
+		}
+	}
+
+	return rows.Err()
+}
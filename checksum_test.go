@@ -0,0 +1,30 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithChecksums())
+	s.UpdateSQL(1, "CREATE TABLE ck(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var s2 Schema
+	s2.SetDialect(DialectSQLite)
+	s2.Configure(WithChecksums())
+	s2.UpdateSQL(1, "CREATE TABLE ck(x INT, y INT)")
+	s2.UpdateSQL(2, "CREATE TABLE ck2(x INT)")
+	var mm *ChecksumMismatchError
+	if er := s2.Install(db, 2); !errors.As(er, &mm) || mm.Version != 1 {
+		t.Fatal(er)
+	}
+	s.UpdateSQL(2, "CREATE TABLE ck2(x INT)")
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+}
@@ -0,0 +1,192 @@
+// Command migrate applies SQL migrations stored in a directory to a database.
+// The directory is laid out as described by migrate.Schema.AddDir.
+//
+// Usage:
+//
+//	migrate -driver NAME -dsn DSN -dir DIR COMMAND [ARGS]
+//
+// The commands are:
+//
+//	up [VERSION]      apply pending migrations, up to VERSION (default: the latest)
+//	down [VERSION]    roll back to VERSION (default: the version before the current one)
+//	status            list the migrations and whether each has been applied
+//	version           print the database's current version
+//	create NAME       write an empty VERSION_NAME.up.sql and .down.sql pair to DIR
+//
+// create needs no -dsn. It versions the new migration with the current Unix
+// time, which orders migrations written on different branches by when they
+// were created while still fitting the INT columns migrate records versions
+// in.
+//
+// The postgres, mysql, sqlite and sqlserver drivers are built in.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lye/migrate"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/microsoft/go-mssqldb"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	driver := flag.String("driver", "postgres", "database/sql driver name")
+	dsn := flag.String("dsn", "", "data source name")
+	dir := flag.String("dir", "migrations", "directory holding the migration files")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 || *dsn == "" && flag.Arg(0) != "create" {
+		usage()
+		os.Exit(2)
+	}
+
+	var er error
+	if flag.Arg(0) == "create" {
+		er = create(*dir, flag.Args()[1:], time.Now())
+
+	} else {
+		er = run(*driver, *dsn, *dir, flag.Arg(0), flag.Args()[1:])
+	}
+
+	if er != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", er)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: migrate -driver NAME -dsn DSN -dir DIR up [VERSION] | down [VERSION] | status | version\n       migrate -dir DIR create NAME\n")
+	flag.PrintDefaults()
+}
+
+func run(driver, dsn, dir, command string, args []string) error {
+	var schema migrate.Schema
+	schema.SetDialect(migrate.DialectFor(driver))
+
+	if er := schema.AddDir(os.DirFS(dir), "."); er != nil {
+		return er
+	}
+
+	db, er := sql.Open(driver, dsn)
+	if er != nil {
+		return er
+	}
+	defer db.Close()
+
+	versions := schema.Versions()
+
+	switch command {
+	case "up":
+		target := 0
+		if len(versions) > 0 {
+			target = versions[len(versions)-1]
+		}
+
+		if er := versionArg(args, &target); er != nil {
+			return er
+		}
+
+		res, er := schema.InstallResult(db, target)
+		if er != nil {
+			return er
+		}
+
+		fmt.Printf("%d -> %d (%d applied)\n", res.From, res.To, len(res.Applied))
+
+	case "down":
+		current, er := migrate.Version(db)
+		if er != nil {
+			return er
+		}
+
+		target := 0
+		for _, v := range versions {
+			if v < current {
+				target = v
+			}
+		}
+
+		if er := versionArg(args, &target); er != nil {
+			return er
+		}
+
+		if er := schema.Rollback(db, target); er != nil {
+			return er
+		}
+
+		fmt.Printf("%d -> %d\n", current, target)
+
+	case "status":
+		table, er := schema.StatusTable(db)
+		if er != nil {
+			return er
+		}
+
+		fmt.Print(table)
+
+	case "version":
+		current, er := migrate.Version(db)
+		if er != nil {
+			return er
+		}
+
+		fmt.Println(current)
+
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return nil
+}
+
+func versionArg(args []string, version *int) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	v, er := strconv.Atoi(args[0])
+	if er != nil {
+		return fmt.Errorf("invalid version %q", args[0])
+	}
+
+	*version = v
+	return nil
+}
+
+// create writes the empty up and down files of a new migration named by args
+// to dir, refusing to overwrite existing files.
+func create(dir string, args []string, now time.Time) error {
+	if len(args) != 1 || args[0] == "" || strings.ContainsAny(args[0], `/\`) {
+		return fmt.Errorf("create needs a migration name")
+	}
+
+	version := now.Unix()
+
+	for _, direction := range []string{"up", "down"} {
+		name := filepath.Join(dir, fmt.Sprintf("%d_%s.%s.sql", version, args[0], direction))
+
+		f, er := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if er != nil {
+			return er
+		}
+
+		if er := f.Close(); er != nil {
+			return er
+		}
+
+		fmt.Println(name)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// InstallDebug is like Install, but logs every statement run through the
+// migration transaction (including migrate's own bookkeeping) to l, along with
+// how long it took and, for Exec, the number of rows affected. Only SQL
+// migrations and closures registered with Schema.UpdateExec are covered, as
+// closures taking a raw *sql.Tx bypass any wrapping. This is meant for
+// development and staging; it is never enabled except by calling InstallDebug
+// explicitly.
+func (s *Schema) InstallDebug(db *sql.DB, maxVersion int, l *log.Logger) error {
+	wrap := s.txWrapper
+	debug := s.clone()
+	debug.txWrapper = func(tx *sql.Tx) QueryExecutor {
+		var q QueryExecutor = tx
+		if wrap != nil {
+			q = wrap(tx)
+		}
+
+		return &debugExecutor{QueryExecutor: q, log: l}
+	}
+
+	return debug.Install(db, maxVersion)
+}
+
+type debugExecutor struct {
+	QueryExecutor
+	log *log.Logger
+}
+
+func (d *debugExecutor) logExec(query string, start time.Time, res sql.Result, er error) {
+	if er != nil {
+		d.log.Printf("migrate: %s (%v): %v", query, time.Since(start), er)
+		return
+	}
+
+	rows, er := res.RowsAffected()
+	if er != nil {
+		rows = -1
+	}
+
+	d.log.Printf("migrate: %s (%v, %d rows)", query, time.Since(start), rows)
+}
+
+func (d *debugExecutor) logQuery(query string, start time.Time, er error) {
+	if er != nil {
+		d.log.Printf("migrate: %s (%v): %v", query, time.Since(start), er)
+
+	} else {
+		d.log.Printf("migrate: %s (%v)", query, time.Since(start))
+	}
+}
+
+func (d *debugExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, er := d.QueryExecutor.Exec(query, args...)
+	d.logExec(query, start, res, er)
+	return res, er
+}
+
+func (d *debugExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, er := d.QueryExecutor.ExecContext(ctx, query, args...)
+	d.logExec(query, start, res, er)
+	return res, er
+}
+
+func (d *debugExecutor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, er := d.QueryExecutor.Query(query, args...)
+	d.logQuery(query, start, er)
+	return rows, er
+}
+
+func (d *debugExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, er := d.QueryExecutor.QueryContext(ctx, query, args...)
+	d.logQuery(query, start, er)
+	return rows, er
+}
+
+func (d *debugExecutor) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.QueryExecutor.QueryRow(query, args...)
+	d.logQuery(query, start, row.Err())
+	return row
+}
+
+func (d *debugExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.QueryExecutor.QueryRowContext(ctx, query, args...)
+	d.logQuery(query, start, row.Err())
+	return row
+}
@@ -0,0 +1,21 @@
+package migrate
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestInstallDebug(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE dbg(x INT); INSERT INTO dbg VALUES(1)")
+	var b strings.Builder
+	if er := s.InstallDebug(db, 1, log.New(&b, "", 0)); er != nil {
+		t.Fatal(er)
+	}
+	out := b.String()
+	if !strings.Contains(out, "migrate: CREATE TABLE dbg(x INT) (") || !strings.Contains(out, "migrate: INSERT INTO dbg VALUES(1) (") || !strings.Contains(out, ", 1 rows)") {
+		t.Fatal(out)
+	}
+}
@@ -0,0 +1,386 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Querier is the subset of *sql.DB, *sql.Conn and *sql.Tx used to run the
+// bookkeeping queries.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DB is the subset of *sql.DB that Schema.Install, Schema.InstallResult and
+// Version need, so that wrapped or instrumented databases and test doubles can
+// be passed in; *sql.DB and *sql.Conn satisfy it. Migrations are only run under
+// the Dialect's lock if the DB also has a Conn method like *sql.DB's, as the
+// lock must be held on a single connection.
+type DB interface {
+	Querier
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// connector is implemented by DBs, such as *sql.DB, that can hand out a
+// dedicated connection.
+type connector interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// Dialect describes the RDBMS-specific SQL that migrate uses to maintain its
+// version table. Unless one is set via Schema.SetDialect, a Schema uses the
+// built-in Dialect for the driver of the *sql.DB it is given, if it knows the
+// driver, and otherwise a generic dialect (which assumes `$1`-style
+// placeholders, transactional DDL and does no locking).
+type Dialect interface {
+	// Placeholder returns the bind parameter for the n'th (1-based) argument
+	// of a statement.
+	Placeholder(n int) string
+
+	// CreateVersionTable returns the DDL that creates the named version table.
+	CreateVersionTable(table string) string
+
+	// TableExists reports whether the named table exists.
+	TableExists(ctx context.Context, q Querier, table string) (bool, error)
+
+	// Lock acquires an exclusive session-level lock, identified by key, on
+	// conn. It is held until Unlock is called, and migrations are only
+	// applied while it is held.
+	Lock(ctx context.Context, conn *sql.Conn, key string) error
+	Unlock(ctx context.Context, conn *sql.Conn, key string) error
+
+	// TransactionalDDL reports whether DDL statements take part in
+	// transactions, i.e. whether they are undone by a rollback.
+	TransactionalDDL() bool
+
+	// Savepoint, RollbackToSavepoint and ReleaseSavepoint return the
+	// statements that create, roll back to and release the named savepoint.
+	// ReleaseSavepoint may return "" if the dialect has no such statement.
+	Savepoint(name string) string
+	RollbackToSavepoint(name string) string
+	ReleaseSavepoint(name string) string
+}
+
+// ErrLockFailed is returned when a dialect could not acquire the migration lock.
+var ErrLockFailed = errors.New("migrate: could not acquire migration lock")
+
+var (
+	// DialectMySQL is the Dialect for MySQL and MariaDB. Locking is done via
+	// GET_LOCK. MySQL commits implicitly around DDL statements, so under
+	// TxAuto migrations are applied with TxPerMigration.
+	DialectMySQL Dialect = mysqlDialect{}
+
+	// DialectSQLServer is the Dialect for Microsoft SQL Server. Locking is
+	// done via sp_getapplock with a session-owned lock.
+	DialectSQLServer Dialect = sqlServerDialect{}
+
+	// DialectPostgres is the Dialect for PostgreSQL. Tables are looked up with
+	// to_regclass, so the search_path is honoured, and locking is done via
+	// session-level advisory locks, keyed by AdvisoryLockID.
+	DialectPostgres Dialect = postgresDialect{}
+
+	// DialectSQLite is the Dialect for SQLite. Tables are looked up in
+	// sqlite_master.
+	DialectSQLite Dialect = sqliteDialect{}
+)
+
+// DialectFor returns the built-in Dialect for the database/sql driver
+// registered as driverName, or nil (the generic default) if there is none.
+func DialectFor(driverName string) Dialect {
+	switch driverName {
+	case "mysql":
+		return DialectMySQL
+
+	case "sqlserver", "mssql":
+		return DialectSQLServer
+
+	case "postgres", "pgx":
+		return DialectPostgres
+
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	}
+
+	return nil
+}
+
+// driverDialects maps the import paths of known database/sql drivers to
+// their Dialects.
+var driverDialects = []struct {
+	path    string
+	dialect Dialect
+}{
+	{"github.com/lib/pq", DialectPostgres},
+	{"github.com/jackc/pgx", DialectPostgres},
+	{"github.com/go-sql-driver/mysql", DialectMySQL},
+	{"github.com/microsoft/go-mssqldb", DialectSQLServer},
+	{"github.com/denisenkom/go-mssqldb", DialectSQLServer},
+	{"modernc.org/sqlite", DialectSQLite},
+	{"github.com/mattn/go-sqlite3", DialectSQLite},
+	{"github.com/ncruces/go-sqlite3", DialectSQLite},
+	{"github.com/glebarez/go-sqlite", DialectSQLite},
+}
+
+// detectDialect returns the built-in Dialect for the driver of db, or nil if
+// db isn't a *sql.DB or its driver isn't one of driverDialects.
+func detectDialect(db DB) Dialect {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return nil
+	}
+
+	t := reflect.TypeOf(sqlDB.Driver())
+	if t == nil {
+		return nil
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	path := t.PkgPath()
+	for _, d := range driverDialects {
+		if path == d.path || strings.HasPrefix(path, d.path+"/") {
+			return d.dialect
+		}
+	}
+
+	return nil
+}
+
+// forDB returns s, or if s has no Dialect set but one can be detected for db,
+// a clone of s using that Dialect.
+func (s *Schema) forDB(db DB) *Schema {
+	if s.dialect != nil {
+		return s
+	}
+
+	d := detectDialect(db)
+	if d == nil {
+		return s
+	}
+
+	c := s.clone()
+	c.dialect = d
+	return c
+}
+
+// clone returns a copy of s, for running with adjusted settings. The copy is
+// guarded as s (see guard).
+func (s *Schema) clone() *Schema {
+	c := *s
+	if c.origin == nil {
+		c.origin = s
+	}
+
+	return &c
+}
+
+func (s *Schema) getDialect() Dialect {
+	if s.dialect == nil {
+		return genericDialect{}
+	}
+
+	return s.dialect
+}
+
+type genericDialect struct{}
+
+func (genericDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (genericDialect) CreateVersionTable(table string) string {
+	return "CREATE TABLE " + table + "(version INT, " + singleRow + ")"
+}
+
+// singleRow is the column definition that keeps a second row out of the
+// version table: every row takes the default, and the default is unique.
+const singleRow = "singleton INT NOT NULL DEFAULT 1 UNIQUE CHECK (singleton = 1)"
+
+// TableExists has no portable catalog to consult, so it selects from the table
+// and treats an error reporting a missing relation (see IsUndefinedObject) as
+// the table not existing. Any other error, such as a lost connection or a
+// permission failure, is returned rather than mistaken for a missing table.
+func (genericDialect) TableExists(ctx context.Context, q Querier, table string) (bool, error) {
+	rows, er := q.QueryContext(ctx, "SELECT * FROM "+table+" WHERE 1 = 0")
+	if IsUndefinedObject(er) {
+		return false, nil
+
+	} else if er != nil {
+		return false, er
+	}
+
+	return true, rows.Close()
+}
+
+func (genericDialect) Lock(ctx context.Context, conn *sql.Conn, key string) error {
+	return nil
+}
+
+func (genericDialect) Unlock(ctx context.Context, conn *sql.Conn, key string) error {
+	return nil
+}
+
+func (genericDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (genericDialect) Savepoint(name string) string {
+	return "SAVEPOINT " + name
+}
+
+func (genericDialect) RollbackToSavepoint(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+func (genericDialect) ReleaseSavepoint(name string) string {
+	return "RELEASE SAVEPOINT " + name
+}
+
+type postgresDialect struct {
+	genericDialect
+}
+
+func (postgresDialect) TableExists(ctx context.Context, q Querier, table string) (bool, error) {
+	var exists bool
+	if er := q.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); er != nil {
+		return false, er
+	}
+
+	return exists, nil
+}
+
+func (postgresDialect) Lock(ctx context.Context, conn *sql.Conn, key string) error {
+	_, er := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", AdvisoryLockID(key))
+	return er
+}
+
+func (postgresDialect) Unlock(ctx context.Context, conn *sql.Conn, key string) error {
+	_, er := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", AdvisoryLockID(key))
+	return er
+}
+
+type sqliteDialect struct {
+	genericDialect
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) TableExists(ctx context.Context, q Querier, table string) (bool, error) {
+	var count int
+	if er := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); er != nil {
+		return false, er
+	}
+
+	return count > 0, nil
+}
+
+type mysqlDialect struct {
+	genericDialect
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) CreateVersionTable(table string) string {
+	return "CREATE TABLE " + table + " (version INT NOT NULL, " + singleRow + ")"
+}
+
+func (mysqlDialect) TableExists(ctx context.Context, q Querier, table string) (bool, error) {
+	var count int
+	er := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", table).Scan(&count)
+	if er != nil {
+		return false, er
+	}
+
+	return count > 0, nil
+}
+
+func (mysqlDialect) Lock(ctx context.Context, conn *sql.Conn, key string) error {
+	var status sql.NullInt64
+	if er := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", "migrate:"+key).Scan(&status); er != nil {
+		return er
+	}
+
+	if !status.Valid || status.Int64 != 1 {
+		return ErrLockFailed
+	}
+
+	return nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sql.Conn, key string) error {
+	_, er := conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", "migrate:"+key)
+	return er
+}
+
+func (mysqlDialect) TransactionalDDL() bool {
+	return false
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Placeholder(n int) string {
+	return fmt.Sprintf("@p%d", n)
+}
+
+func (sqlServerDialect) CreateVersionTable(table string) string {
+	return "CREATE TABLE " + table + " (version INT NOT NULL, " + singleRow + ")"
+}
+
+func (sqlServerDialect) TableExists(ctx context.Context, q Querier, table string) (bool, error) {
+	var count int
+	if er := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM sys.tables WHERE name = @p1", table).Scan(&count); er != nil {
+		return false, er
+	}
+
+	return count > 0, nil
+}
+
+func (sqlServerDialect) Lock(ctx context.Context, conn *sql.Conn, key string) error {
+	var status int
+	er := conn.QueryRowContext(ctx, `DECLARE @r INT;
+EXEC @r = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1;
+SELECT @r`, "migrate:"+key).Scan(&status)
+	if er != nil {
+		return er
+	}
+
+	// sp_getapplock returns 0 or 1 on success and a negative status otherwise.
+	if status < 0 {
+		return ErrLockFailed
+	}
+
+	return nil
+}
+
+func (sqlServerDialect) Unlock(ctx context.Context, conn *sql.Conn, key string) error {
+	_, er := conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", "migrate:"+key)
+	return er
+}
+
+func (sqlServerDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (sqlServerDialect) Savepoint(name string) string {
+	return "SAVE TRANSACTION " + name
+}
+
+func (sqlServerDialect) RollbackToSavepoint(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+func (sqlServerDialect) ReleaseSavepoint(name string) string {
+	return ""
+}
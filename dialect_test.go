@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestSQLiteDialect(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectFor("sqlite"))
+	s.Configure(WithHistory(), WithAppliedBy(nil), WithIdempotentDDL(), WithDirtyFlag())
+	s.UpdateSQL(1, "CREATE TABLE q(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if v, er := Version(db); er != nil || v != 1 {
+		t.Fatal(v, er)
+	}
+	if d, er := s.DumpSchema(db); er != nil || d != "CREATE TABLE q(x INT);\n\n" {
+		t.Fatalf("%q %v", d, er)
+	}
+	s.Rollback(db, 0)
+}
+
+func TestDetectDialect(t *testing.T) {
+	db := openDB(t)
+	if d := detectDialect(db); d != DialectSQLite {
+		t.Fatalf("%T", d)
+	}
+	if d := detectDialect(taggedDB{DB: db}); d != nil {
+		t.Fatalf("%T", d)
+	}
+
+	var s Schema
+	if _, ok := s.forDB(db).getDialect().(sqliteDialect); !ok {
+		t.Fatal("not detected")
+	}
+	s.SetDialect(DialectMySQL)
+	if s.forDB(db) != &s {
+		t.Fatal("explicit dialect overridden")
+	}
+
+	// The detected dialect's copy of the Schema is guarded as the Schema.
+	var nested error
+	var r Schema
+	r.Update(1, func(v int, tx *sql.Tx) error {
+		nested = r.Install(db, 1)
+		return nil
+	})
+	if er := r.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if !errors.Is(nested, ErrInstallInProgress) {
+		t.Fatal(nested)
+	}
+}
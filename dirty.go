@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrDirtyDatabase is returned when dirty tracking is enabled and a previous
+// run was interrupted part-way through a migration, possibly leaving partial
+// changes behind. The database must be repaired by hand and then marked clean
+// with Schema.ForceClean.
+var ErrDirtyDatabase = errors.New("migrate: database is dirty")
+
+// WithDirtyFlag enables dirty tracking. A `dirty` column (an INT holding 0 or
+// 1, as not every RDBMS has a BOOLEAN type) is added to the version table if
+// needed. Each migration transaction sets it before running any migration and
+// clears it when stamping the new version, so on an RDBMS with transactional
+// DDL a failure simply rolls the flag back, while on one without (MySQL) a
+// failure leaves the flag set. Install and Rollback refuse to run against a
+// dirty database, returning ErrDirtyDatabase.
+func WithDirtyFlag() Option {
+	return func(s *Schema) {
+		s.dirtyFlag = true
+	}
+}
+
+// ForceClean clears the dirty flag and stamps the database with version, once
+// an operator has checked (and if need be repaired) the state an interrupted
+// migration left behind.
+func (s *Schema) ForceClean(db *sql.DB, version int) error {
+	s = s.forDB(db)
+	ctx := context.Background()
+
+	return s.session(ctx, db, func(conn DB) error {
+		return s.transact(ctx, conn, func(tx *sql.Tx, current int) error {
+			if er := s.setDbVersion(ctx, tx, current, version); er != nil {
+				return er
+			}
+
+			return s.setDirty(ctx, tx, false)
+		})
+	})
+}
+
+// ensureColumn adds the named column to table unless it already has it.
+func (s *Schema) ensureColumn(ctx context.Context, q Querier, table, column, definition string) error {
+	if hasColumn(ctx, q, table, column) {
+		return nil
+	}
+
+	_, er := q.ExecContext(ctx, "ALTER TABLE "+table+" ADD "+column+" "+definition)
+	return er
+}
+
+// hasColumn reports whether table has the named column.
+func hasColumn(ctx context.Context, q Querier, table, column string) bool {
+	rows, er := q.QueryContext(ctx, "SELECT "+column+" FROM "+table+" WHERE 1 = 0")
+	if er != nil {
+		return false
+	}
+
+	rows.Close()
+	return true
+}
+
+// checkClean returns ErrDirtyDatabase if dirty tracking is enabled and the
+// dirty flag is set.
+func (s *Schema) checkClean(ctx context.Context, q Querier) error {
+	if !s.dirtyFlag {
+		return nil
+	}
+
+	var version, dirty int
+	er := q.QueryRowContext(ctx, "SELECT version, dirty FROM "+versionTable).Scan(&version, &dirty)
+	if er == sql.ErrNoRows {
+		return nil
+	}
+
+	if er != nil {
+		return er
+	}
+
+	if dirty != 0 {
+		return fmt.Errorf("%w: a migration past version %d did not complete", ErrDirtyDatabase, version)
+	}
+
+	return nil
+}
+
+func (s *Schema) setDirty(ctx context.Context, tx *sql.Tx, dirty bool) error {
+	if !s.dirtyFlag {
+		return nil
+	}
+
+	value := 0
+	if dirty {
+		value = 1
+	}
+
+	_, er := s.executor(tx).ExecContext(ctx, "UPDATE "+versionTable+" SET dirty = "+s.getDialect().Placeholder(1), value)
+	return er
+}
@@ -0,0 +1,28 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestDirty(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithDirtyFlag())
+	s.Update(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE a(x INT)"); return er })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	db.Exec("UPDATE version SET dirty = 1")
+	s.Update(2, func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 2); !errors.Is(er, ErrDirtyDatabase) {
+		t.Fatal(er)
+	}
+	if er := s.ForceClean(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+}
@@ -0,0 +1,360 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DumpSchema introspects db, typically a throwaway database that Install has
+// just been run against, and returns a CREATE TABLE statement for each user
+// table, ordered by table name, so that the result can be committed and
+// diffed to catch unintended schema changes. migrate's own tables are left
+// out. MySQL tables are dumped with SHOW CREATE TABLE and SQLite tables (with
+// DialectSQLite, or else if the generic dialect finds sqlite_master) from
+// sqlite_master; otherwise the statements are rebuilt from
+// information_schema, covering column names, types, nullability and defaults
+// but not constraints or indexes.
+func (s *Schema) DumpSchema(db *sql.DB) (string, error) {
+	s = s.forDB(db)
+	statements, er := s.dumpStatements(context.Background(), db)
+	if er != nil {
+		return "", er
+	}
+
+	var dump strings.Builder
+	for _, statement := range statements {
+		dump.WriteString(statement)
+		dump.WriteString(";\n\n")
+	}
+
+	return dump.String(), nil
+}
+
+// SchemaMismatchError is returned by Schema.VerifyBaseline when a database's
+// schema differs from the one its migrations produce. Missing holds the CREATE
+// TABLE statements that the migrations produce but the database lacks, and
+// Unexpected those the database has but the migrations don't produce; a table
+// whose definition differs appears in both. They are rendered as by
+// DumpSchema, except that SQLite tables are rebuilt from their column
+// information, as for information_schema.
+type SchemaMismatchError struct {
+	Version    int
+	Missing    []string
+	Unexpected []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("migrate: schema differs from migrations up to version %d: missing %q, unexpected %q", e.Version, e.Missing, e.Unexpected)
+}
+
+// VerifyBaseline checks that the schema of db matches the one that migrations
+// up to version produce, e.g. after stamping a legacy database with version
+// via AdoptFrom or DetectExistingVersion. The migrations are installed into
+// scratch, which must be an empty throwaway database of the same kind as db
+// (such as an in-memory SQLite database when db is SQLite), and the tables of
+// both are then compared, returning a SchemaMismatchError if they differ. The
+// scratch install is not a real one, so it isn't reported to the AuditSink,
+// Hooks or AfterCommit hooks, nor logged by WithSQLLog, and WithDatabase and
+// WithVersionLog don't apply to it. It only reads from db.
+func (s *Schema) VerifyBaseline(db, scratch *sql.DB, version int) error {
+	s = s.forDB(db)
+
+	install := s.clone()
+	install.auditSink, install.hooks, install.afterCommit = nil, nil, nil
+	install.database, install.versionLog, install.sqlLog, install.dryRun = "", false, nil, false
+	if er := install.Install(scratch, version); er != nil {
+		return fmt.Errorf("migrate: scratch database: %w", er)
+	}
+
+	ctx := context.Background()
+
+	expected, er := s.tableDefinitions(ctx, scratch)
+	if er != nil {
+		return er
+	}
+
+	actual, er := s.tableDefinitions(ctx, db)
+	if er != nil {
+		return er
+	}
+
+	missing, unexpected := subtract(expected, actual), subtract(actual, expected)
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+
+	return &SchemaMismatchError{Version: version, Missing: missing, Unexpected: unexpected}
+}
+
+// tableDefinitions is like dumpStatements, but rebuilds SQLite tables from
+// their columns rather than returning the text of their CREATE TABLE
+// statements, which ALTER TABLE edits in place, so that equivalent tables
+// compare equal however they came about.
+func (s *Schema) tableDefinitions(ctx context.Context, db *sql.DB) ([]string, error) {
+	switch s.getDialect().(type) {
+	case mysqlDialect, sqlServerDialect, postgresDialect:
+		return s.dumpStatements(ctx, db)
+
+	case sqliteDialect:
+		return dumpSQLiteColumns(ctx, db, s.internalTable)
+	}
+
+	if statements, er := dumpSQLiteColumns(ctx, db, s.internalTable); er == nil {
+		return statements, nil
+	}
+
+	return s.dumpStatements(ctx, db)
+}
+
+// subtract returns the statements of a that b lacks.
+func subtract(a, b []string) []string {
+	present := make(map[string]bool, len(b))
+	for _, statement := range b {
+		present[statement] = true
+	}
+
+	var rest []string
+	for _, statement := range a {
+		if !present[statement] {
+			rest = append(rest, statement)
+		}
+	}
+
+	return rest
+}
+
+// dumpStatements returns the CREATE TABLE statement of each user table of db,
+// ordered by table name.
+func (s *Schema) dumpStatements(ctx context.Context, db *sql.DB) ([]string, error) {
+	var statements []string
+	var er error
+
+	switch s.getDialect().(type) {
+	case mysqlDialect:
+		statements, er = dumpMySQL(ctx, db, s.internalTable)
+
+	case sqlServerDialect:
+		statements, er = dumpInformationSchema(ctx, db, "SCHEMA_NAME()", s.internalTable)
+
+	case postgresDialect:
+		statements, er = dumpInformationSchema(ctx, db, "current_schema()", s.internalTable)
+
+	case sqliteDialect:
+		statements, er = dumpSQLite(ctx, db, s.internalTable)
+
+	default:
+		statements, er = dumpSQLite(ctx, db, s.internalTable)
+		if er != nil {
+			statements, er = dumpInformationSchema(ctx, db, "current_schema()", s.internalTable)
+		}
+	}
+
+	return statements, er
+}
+
+// internalTable reports whether table is one of migrate's own.
+func (s *Schema) internalTable(table string) bool {
+	switch table = strings.ToLower(table); table {
+	case versionTable, eventsTable, versionLogTable, lockTable:
+		return true
+	}
+
+	return table == strings.ToLower(s.historyTable())
+}
+
+func dumpSQLite(ctx context.Context, db *sql.DB, internalTable func(string) bool) ([]string, error) {
+	rows, er := db.QueryContext(ctx, "SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if er != nil {
+		return nil, er
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var name, statement string
+		if er := rows.Scan(&name, &statement); er != nil {
+			return nil, er
+		}
+
+		if !internalTable(name) {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements, rows.Err()
+}
+
+// dumpSQLiteColumns rebuilds a CREATE TABLE statement for each user table of
+// db from pragma_table_info, covering column names, types, nullability,
+// defaults and primary key columns.
+func dumpSQLiteColumns(ctx context.Context, db *sql.DB, internalTable func(string) bool) ([]string, error) {
+	rows, er := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if er != nil {
+		return nil, er
+	}
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if er := rows.Scan(&table); er != nil {
+			rows.Close()
+			return nil, er
+		}
+
+		if !internalTable(table) {
+			tables = append(tables, table)
+		}
+	}
+
+	rows.Close()
+	if er := rows.Err(); er != nil {
+		return nil, er
+	}
+
+	statements := make([]string, len(tables))
+	for i, table := range tables {
+		columns, er := sqliteColumns(ctx, db, table)
+		if er != nil {
+			return nil, er
+		}
+
+		statements[i] = "CREATE TABLE " + table + " (\n\t" + strings.Join(columns, ",\n\t") + "\n)"
+	}
+
+	return statements, nil
+}
+
+func sqliteColumns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, er := db.QueryContext(ctx, "SELECT name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid", table)
+	if er != nil {
+		return nil, er
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name, dataType string
+		var notNull, pk int
+		var def sql.NullString
+
+		if er := rows.Scan(&name, &dataType, &notNull, &def, &pk); er != nil {
+			return nil, er
+		}
+
+		definition := name + " " + strings.ToUpper(dataType)
+		if notNull != 0 {
+			definition += " NOT NULL"
+		}
+
+		if def.Valid {
+			definition += " DEFAULT " + def.String
+		}
+
+		if pk > 0 {
+			definition += " PRIMARY KEY"
+		}
+
+		columns = append(columns, definition)
+	}
+
+	return columns, rows.Err()
+}
+
+func dumpMySQL(ctx context.Context, db *sql.DB, internalTable func(string) bool) ([]string, error) {
+	rows, er := db.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name")
+	if er != nil {
+		return nil, er
+	}
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if er := rows.Scan(&table); er != nil {
+			rows.Close()
+			return nil, er
+		}
+
+		if !internalTable(table) {
+			tables = append(tables, table)
+		}
+	}
+
+	rows.Close()
+	if er := rows.Err(); er != nil {
+		return nil, er
+	}
+
+	statements := make([]string, len(tables))
+	for i, table := range tables {
+		var name string
+		if er := db.QueryRowContext(ctx, "SHOW CREATE TABLE `"+table+"`").Scan(&name, &statements[i]); er != nil {
+			return nil, er
+		}
+	}
+
+	return statements, nil
+}
+
+// dumpInformationSchema rebuilds CREATE TABLE statements from
+// information_schema.columns for the tables of the schema named by the SQL
+// expression schema.
+func dumpInformationSchema(ctx context.Context, db *sql.DB, schema string, internalTable func(string) bool) ([]string, error) {
+	rows, er := db.QueryContext(ctx, `SELECT c.table_name, c.column_name, c.data_type, c.character_maximum_length, c.is_nullable, c.column_default
+FROM information_schema.columns c
+JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+WHERE c.table_schema = `+schema+` AND t.table_type = 'BASE TABLE'
+ORDER BY c.table_name, c.ordinal_position`)
+	if er != nil {
+		return nil, er
+	}
+	defer rows.Close()
+
+	var statements []string
+	var table string
+	var columns []string
+
+	flush := func() {
+		if table != "" && !internalTable(table) {
+			statements = append(statements, "CREATE TABLE "+table+" (\n\t"+strings.Join(columns, ",\n\t")+"\n)")
+		}
+	}
+
+	for rows.Next() {
+		var name, column, dataType, nullable string
+		var length sql.NullInt64
+		var def sql.NullString
+
+		if er := rows.Scan(&name, &column, &dataType, &length, &nullable, &def); er != nil {
+			return nil, er
+		}
+
+		if name != table {
+			flush()
+			table, columns = name, nil
+		}
+
+		definition := column + " " + dataType
+		if length.Valid && length.Int64 > 0 {
+			definition += fmt.Sprintf("(%d)", length.Int64)
+		}
+
+		if nullable == "NO" {
+			definition += " NOT NULL"
+		}
+
+		if def.Valid {
+			definition += " DEFAULT " + def.String
+		}
+
+		columns = append(columns, definition)
+	}
+
+	if er := rows.Err(); er != nil {
+		return nil, er
+	}
+
+	flush()
+	return statements, nil
+}
@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDump(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE b(x INT); CREATE TABLE a(y TEXT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	d, er := s.DumpSchema(db)
+	if er != nil || d != "CREATE TABLE a(y TEXT);\n\nCREATE TABLE b(x INT);\n\n" {
+		t.Fatalf("%q %v", d, er)
+	}
+}
+
+func TestVerifyBaseline(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE a(x INT)")
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE a(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE b(x INT)")
+	if er := s.VerifyBaseline(db, openDB(t), 1); er != nil {
+		t.Fatal(er)
+	}
+	var me *SchemaMismatchError
+	if er := s.VerifyBaseline(db, openDB(t), 2); !errors.As(er, &me) || len(me.Missing) != 1 {
+		t.Fatal(er)
+	}
+}
+
+// countingSink is an AuditSink that counts the records it receives.
+type countingSink struct{ applied, failed int }
+
+func (c *countingSink) RecordApplied(context.Context, int, string, time.Duration) error {
+	c.applied++
+	return nil
+}
+
+func (c *countingSink) RecordFailed(context.Context, int, string, error) error {
+	c.failed++
+	return nil
+}
+
+func TestVerifyBaselineAlteredTable(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE a(x int)")
+	db.Exec("ALTER TABLE a ADD COLUMN y text NOT NULL DEFAULT ''")
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE a (\n\tx INT,\n\ty TEXT NOT NULL DEFAULT ''\n)")
+	if er := s.VerifyBaseline(db, openDB(t), 1); er != nil {
+		t.Fatal(er)
+	}
+
+	db.Exec("ALTER TABLE a ADD COLUMN z INT")
+	var me *SchemaMismatchError
+	if er := s.VerifyBaseline(db, openDB(t), 1); !errors.As(er, &me) || len(me.Missing) != 1 || len(me.Unexpected) != 1 {
+		t.Fatal(er)
+	}
+}
+
+func TestVerifyBaselineScratchInstall(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE a(x INT)")
+	sink, hooks, after := &countingSink{}, &recHooks{}, 0
+	var s Schema
+	s.Configure(WithAuditSink(sink), WithHooks(hooks), WithDryRun())
+	s.AfterCommit(func(*sql.DB) error { after++; return nil })
+	s.UpdateSQL(1, "CREATE TABLE a(x INT)")
+	if er := s.VerifyBaseline(db, openDB(t), 1); er != nil {
+		t.Fatal(er)
+	}
+	if sink.applied != 0 || len(hooks.log) != 0 || after != 0 {
+		t.Fatal(sink, hooks.log, after)
+	}
+}
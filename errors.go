@@ -0,0 +1,189 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// sqlState returns the five-character SQLSTATE carried by err, or "" if the
+// driver doesn't expose one. lib/pq and pgx provide it via a SQLState method;
+// go-sql-driver/mysql only has it as a struct field.
+func sqlState(err error) string {
+	var st interface{ SQLState() string }
+	if errors.As(err, &st) {
+		return st.SQLState()
+	}
+
+	v, ok := errorField(err, "SQLState")
+	if !ok {
+		return ""
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return ""
+		}
+
+		b := make([]byte, v.Len())
+		for i := range b {
+			b[i] = byte(v.Index(i).Uint())
+		}
+
+		return string(b)
+	}
+
+	return ""
+}
+
+// mysqlErrorNumber returns the MySQL server error number carried by err.
+func mysqlErrorNumber(err error) (int, bool) {
+	v, ok := errorField(err, "Number")
+	if !ok {
+		return 0, false
+	}
+
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	}
+
+	return 0, false
+}
+
+// sqlServerErrorNumber returns the SQL Server error number carried by err.
+func sqlServerErrorNumber(err error) (int, bool) {
+	var num interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &num) {
+		return int(num.SQLErrorNumber()), true
+	}
+
+	return 0, false
+}
+
+// errorField finds the first error in err's chain that is a struct (or pointer
+// to one) with the named field, and returns that field's value. It lets the
+// package inspect driver errors without importing the drivers.
+func errorField(err error, name string) (reflect.Value, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+
+		if f := v.FieldByName(name); f.IsValid() {
+			return f, true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// errorClass lists the ways in which each supported driver reports one kind of
+// error: by SQLSTATE (lib/pq, pgx, go-sql-driver/mysql), by MySQL or SQL Server
+// error number, or, for SQLite, which reports most errors under a generic
+// code, by a fragment of the message.
+type errorClass struct {
+	sqlStates        []string
+	mysqlNumbers     []int
+	sqlServerNumbers []int
+	sqliteMessages   []string
+}
+
+func (c *errorClass) matches(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if state := sqlState(err); state != "" {
+		for _, s := range c.sqlStates {
+			if state == s {
+				return true
+			}
+		}
+	}
+
+	if n, ok := mysqlErrorNumber(err); ok {
+		for _, m := range c.mysqlNumbers {
+			if n == m {
+				return true
+			}
+		}
+	}
+
+	if n, ok := sqlServerErrorNumber(err); ok {
+		for _, m := range c.sqlServerNumbers {
+			if n == m {
+				return true
+			}
+		}
+	}
+
+	msg := err.Error()
+	for _, m := range c.sqliteMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	undefinedObject = errorClass{
+		sqlStates:        []string{"42P01", "42703", "42704", "42883", "3F000", "42S02", "42S12", "42S22"},
+		mysqlNumbers:     []int{1051, 1054, 1091, 1146},
+		sqlServerNumbers: []int{207, 208, 3701, 4902, 4924},
+		sqliteMessages:   []string{"no such table", "no such column", "no such index", "no such view", "no such trigger"},
+	}
+
+	uniqueViolation = errorClass{
+		sqlStates:        []string{"23505"},
+		mysqlNumbers:     []int{1062, 1586},
+		sqlServerNumbers: []int{2601, 2627},
+		sqliteMessages:   []string{"UNIQUE constraint failed", "PRIMARY KEY must be unique"},
+	}
+
+	deadlock = errorClass{
+		sqlStates:        []string{"40P01"},
+		mysqlNumbers:     []int{1213},
+		sqlServerNumbers: []int{1205},
+	}
+
+	serializationFailure = errorClass{
+		sqlStates:        []string{"40001"},
+		sqlServerNumbers: []int{3960},
+	}
+)
+
+// IsUndefinedObject reports whether err indicates that a table, column or other
+// object referenced by a statement does not exist.
+func IsUndefinedObject(err error) bool {
+	return undefinedObject.matches(err)
+}
+
+// IsUniqueViolation reports whether err indicates that a statement violated a
+// unique or primary key constraint.
+func IsUniqueViolation(err error) bool {
+	return uniqueViolation.matches(err)
+}
+
+// IsDeadlock reports whether err indicates that the transaction was aborted to
+// break a deadlock.
+func IsDeadlock(err error) bool {
+	return deadlock.matches(err)
+}
+
+// IsSerializationFailure reports whether err indicates that the transaction
+// was aborted because it could not be serialized with concurrent ones.
+func IsSerializationFailure(err error) bool {
+	return serializationFailure.matches(err)
+}
@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// pqError mirrors lib/pq's *pq.Error, which exposes its code via a method.
+type pqError struct{ code string }
+
+func (e *pqError) Error() string    { return "pq: " + e.code }
+func (e *pqError) SQLState() string { return e.code }
+
+// mysqlError mirrors go-sql-driver/mysql's *mysql.MySQLError.
+type mysqlError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (e *mysqlError) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+// mssqlError mirrors microsoft/go-mssqldb's mssql.Error, a value type.
+type mssqlError struct{ Number int32 }
+
+func (e mssqlError) Error() string         { return fmt.Sprintf("mssql: %d", e.Number) }
+func (e mssqlError) SQLErrorNumber() int32 { return e.Number }
+
+func TestErrorClassification(t *testing.T) {
+	var state [5]byte
+	copy(state[:], "42S02")
+
+	for _, c := range []struct {
+		name string
+		err  error
+		is   func(error) bool
+		want bool
+	}{
+		{"pq undefined table", &pqError{"42P01"}, IsUndefinedObject, true},
+		{"pq unique", &pqError{"23505"}, IsUniqueViolation, true},
+		{"pq deadlock", &pqError{"40P01"}, IsDeadlock, true},
+		{"pq serialization", &pqError{"40001"}, IsSerializationFailure, true},
+		{"pq other", &pqError{"42601"}, IsUndefinedObject, false},
+		{"mysql number", &mysqlError{Number: 1146}, IsUndefinedObject, true},
+		{"mysql state", &mysqlError{Number: 9999, SQLState: state}, IsUndefinedObject, true},
+		{"mysql duplicate", &mysqlError{Number: 1062}, IsUniqueViolation, true},
+		{"mysql deadlock", &mysqlError{Number: 1213}, IsDeadlock, true},
+		{"mysql not deadlock", &mysqlError{Number: 1062}, IsDeadlock, false},
+		{"mssql invalid object", mssqlError{208}, IsUndefinedObject, true},
+		{"mssql duplicate key", mssqlError{2627}, IsUniqueViolation, true},
+		{"mssql deadlock", mssqlError{1205}, IsDeadlock, true},
+		{"mssql snapshot", mssqlError{3960}, IsSerializationFailure, true},
+		{"wrapped", fmt.Errorf("migration 3: %w", &pqError{"42P01"}), IsUndefinedObject, true},
+		{"plain", errors.New("no such table: t"), IsUndefinedObject, true},
+		{"nil", nil, IsUndefinedObject, false},
+	} {
+		if got := c.is(c.err); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestErrorClassificationSQLite(t *testing.T) {
+	db := openDB(t)
+	_, er := db.Exec("SELECT * FROM missing")
+	if !IsUndefinedObject(er) || IsUniqueViolation(er) {
+		t.Fatal(er)
+	}
+
+	db.Exec("CREATE TABLE u(x INT PRIMARY KEY)")
+	db.Exec("INSERT INTO u VALUES(1)")
+	_, er = db.Exec("INSERT INTO u VALUES(1)")
+	if !IsUniqueViolation(er) || IsUndefinedObject(er) {
+		t.Fatal(er)
+	}
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// eventsTable is the name of the table WithEvents writes to.
+const eventsTable = "migration_events"
+
+// WithEvents enables the migration_events table, meant to be watched by a
+// change-data-capture pipeline so that consumers see schema changes in the
+// same stream as the data. Every migration applied by Install, and every
+// version reverted by Schema.Rollback, adds a row in the same transaction as
+// the change itself. The table is created when needed, and its layout is
+// fixed:
+//
+//	version     INT           the migration's minVersion
+//	direction   VARCHAR(4)    "up" or "down"
+//	name        VARCHAR(255)  the migration's name, or ""
+//	applied_at  TIMESTAMP     when it happened (DATETIME2 on SQL Server,
+//	                          DATETIME on MySQL)
+//
+// Unlike the history table, rows are never deleted.
+func WithEvents() Option {
+	return func(s *Schema) {
+		s.events = true
+	}
+}
+
+// ensureEventsTable creates the events table if events are enabled and it
+// doesn't exist yet.
+func (s *Schema) ensureEventsTable(ctx context.Context, q Querier) error {
+	if !s.events {
+		return nil
+	}
+
+	exists, er := s.getDialect().TableExists(ctx, q, eventsTable)
+	if er != nil || exists {
+		return er
+	}
+
+	_, er = q.ExecContext(ctx, "CREATE TABLE "+eventsTable+" (version INT NOT NULL, direction VARCHAR(4) NOT NULL, name VARCHAR(255) NOT NULL, applied_at "+s.timestampType()+" NOT NULL)")
+	return er
+}
+
+// recordEvent adds an events row for a change made in tx.
+func (s *Schema) recordEvent(ctx context.Context, tx *sql.Tx, version int, direction, name string) error {
+	if !s.events {
+		return nil
+	}
+
+	d := s.getDialect()
+	_, er := s.executor(tx).ExecContext(ctx, "INSERT INTO "+eventsTable+"(version, direction, name, applied_at) VALUES("+d.Placeholder(1)+", "+d.Placeholder(2)+", "+d.Placeholder(3)+", "+d.Placeholder(4)+")", version, direction, name, s.now())
+	return er
+}
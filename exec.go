@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// QueryExecutor is the interface through which closures registered with
+// Schema.UpdateExec, as well as migrate's own bookkeeping, run statements
+// within a migration transaction. *sql.Tx implements it.
+type QueryExecutor interface {
+	Querier
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// WithTxWrapper sets a function that wraps each migration transaction in a
+// QueryExecutor, e.g. to log, time or rewrite statements. The wrapper applies
+// to SQL migrations, closures registered with Schema.UpdateExec and the
+// version table bookkeeping; closures taking a raw *sql.Tx bypass it. By
+// default the transaction is used as is.
+func WithTxWrapper(wrap func(*sql.Tx) QueryExecutor) Option {
+	return func(s *Schema) {
+		s.txWrapper = wrap
+	}
+}
+
+// UpdateExec is like Update, but the closure is passed the transaction wrapped
+// by the WithTxWrapper in effect when it runs rather than the *sql.Tx itself.
+func (s *Schema) UpdateExec(minVersion int, f func(int, QueryExecutor) error) {
+	s.migrations = append(s.migrations, migration{
+		minVersion: minVersion,
+		up: func(_ context.Context, run *Schema, version int, tx *sql.Tx) (int64, error) {
+			return -1, f(version, run.executor(tx))
+		},
+	})
+}
+
+func (s *Schema) executor(tx *sql.Tx) QueryExecutor {
+	var q QueryExecutor = tx
+	if s.txWrapper != nil {
+		q = s.txWrapper(tx)
+	}
+
+	if s.sqlLog != nil {
+		q = &sqlLogExecutor{QueryExecutor: q, log: s.sqlLog}
+	}
+
+	return q
+}
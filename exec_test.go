@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// recExecutor records the statements run through it.
+type recExecutor struct {
+	QueryExecutor
+	log *[]string
+}
+
+func (r recExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	*r.log = append(*r.log, query)
+	return r.QueryExecutor.Exec(query, args...)
+}
+
+func TestTxWrapper(t *testing.T) {
+	db := openDB(t)
+	var log []string
+	var s Schema
+	s.UpdateExec(1, func(v int, q QueryExecutor) error {
+		_, er := q.Exec("CREATE TABLE tw(x INT)")
+		return er
+	})
+	s.Update(2, func(v int, tx *sql.Tx) error {
+		_, er := tx.Exec("CREATE TABLE tw2(x INT)")
+		return er
+	})
+	// Configured after registration, so only applies if resolved at run time.
+	s.Configure(WithTxWrapper(func(tx *sql.Tx) QueryExecutor {
+		return recExecutor{tx, &log}
+	}))
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if len(log) != 1 || log[0] != "CREATE TABLE tw(x INT)" {
+		t.Fatal(log)
+	}
+}
+
+func TestUpdateExecClone(t *testing.T) {
+	db := openDB(t)
+	var log []string
+	var s Schema
+	s.UpdateExec(1, func(v int, q QueryExecutor) error {
+		_, er := q.Exec("CREATE TABLE ue(x INT)")
+		return er
+	})
+	c := s.clone()
+	c.txWrapper = func(tx *sql.Tx) QueryExecutor { return recExecutor{tx, &log} }
+	if er := c.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if len(log) != 1 || log[0] != "CREATE TABLE ue(x INT)" {
+		t.Fatal(log)
+	}
+}
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ValidationFailedError is returned by Schema.InstallOrRollback when the
+// validation function rejected the migrated database.
+type ValidationFailedError struct {
+	Err error
+}
+
+func (e *ValidationFailedError) Error() string {
+	return "migrate: validation failed, migrations rolled back: " + e.Err.Error()
+}
+
+func (e *ValidationFailedError) Unwrap() error {
+	return e.Err
+}
+
+// InstallOrRollback is like Install, but after applying the migrations passes
+// the migration transaction to validate, and only commits if it returns nil.
+// Otherwise the transaction, migrations included, is rolled back and a
+// ValidationFailedError is returned. Migrations are always applied in a single
+// transaction, whatever the TxMode, so this is only atomic on an RDBMS with
+// transactional DDL. With WithDryRun the transaction is rolled back even if
+// validate accepts it.
+func (s *Schema) InstallOrRollback(db DB, maxVersion int, validate func(*sql.Tx) error) error {
+	s = s.forDB(db)
+	ctx := context.Background()
+	res := &Result{target: maxVersion}
+
+	migrations, er := s.collect()
+	if er != nil {
+		return er
+	}
+
+	if er := s.checkMaxVersion(migrations, maxVersion); er != nil {
+		return er
+	}
+
+	er = s.session(ctx, db, func(conn DB) error {
+		if er := s.preflight(ctx, conn, migrations); er != nil {
+			return er
+		}
+
+		if s.dryRun && !s.getDialect().TransactionalDDL() {
+			return errors.New("migrate: can't dry-run without transactional DDL")
+		}
+
+		er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			if er := s.apply(ctx, tx, migrations, version, maxVersion, res); er != nil {
+				return er
+			}
+
+			if er := validate(tx); er != nil {
+				return &ValidationFailedError{Err: er}
+			}
+
+			if s.dryRun {
+				return errDryRun
+			}
+
+			return nil
+		})
+		if er == errDryRun {
+			return nil
+		}
+		if er != nil {
+			return er
+		}
+
+		res.committed = len(res.Applied)
+		return nil
+	})
+	if auditEr := s.audit(ctx, res, er); er == nil {
+		er = auditEr
+	}
+	if er != nil || s.dryRun {
+		return er
+	}
+
+	return s.runAfterCommit(db)
+}
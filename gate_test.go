@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestInstallOrRollback(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithChecksums())
+	s.UpdateSQL(1, "CREATE TABLE g(x INT)")
+	reject := func(tx *sql.Tx) error { return errors.New("no") }
+	accept := func(tx *sql.Tx) error { return nil }
+
+	var vf *ValidationFailedError
+	if er := s.InstallOrRollback(db, 1, reject); !errors.As(er, &vf) {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+	if er := s.InstallOrRollback(db, 1, accept); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 1 {
+		t.Fatal(v)
+	}
+
+	// The same checks as Install are made first.
+	var edited Schema
+	edited.SetDialect(DialectSQLite)
+	edited.Configure(WithChecksums())
+	edited.UpdateSQL(1, "CREATE TABLE g(x INT, y INT)")
+	edited.UpdateSQL(2, "CREATE TABLE g2(x INT)")
+	var mm *ChecksumMismatchError
+	if er := edited.InstallOrRollback(db, 2, accept); !errors.As(er, &mm) {
+		t.Fatal(er)
+	}
+}
+
+func TestInstallOrRollbackDryRun(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithDryRun())
+	s.UpdateSQL(1, "CREATE TABLE gd(x INT)")
+	validated := false
+	er := s.InstallOrRollback(db, 1, func(tx *sql.Tx) error {
+		validated = true
+		_, er := tx.Exec("SELECT * FROM gd")
+		return er
+	})
+	if er != nil || !validated {
+		t.Fatal(er, validated)
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM gd"); er == nil {
+		t.Fatal("table kept")
+	}
+}
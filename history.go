@@ -0,0 +1,348 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultHistoryTable is the name of the table WithHistory records migrations
+// in, unless WithHistoryTable says otherwise.
+const defaultHistoryTable = "migration_history"
+
+// WithHistory enables the history table, migration_history, which gets a row
+// for every migration applied, written in the same transaction as the
+// migration itself:
+//
+//	version        INT           the migration's minVersion
+//	name           VARCHAR(255)  its name, or "" for closures
+//	applied_at     TIMESTAMP     when it was applied (DATETIME2 on SQL
+//	                             Server, DATETIME on MySQL)
+//	duration_ms    BIGINT        how long its closure took to run
+//	rows_affected  BIGINT        the row count reported by a closure
+//	                             registered with UpdateRows, or NULL
+//	applied_by     VARCHAR(255)  who applied it, with WithAppliedBy, or NULL
+//	description    VARCHAR(255)  its description (see UpdateNamed), or NULL
+//	checksum       VARCHAR(64)   its Migration.Hash (see WithChecksums)
+//	status         VARCHAR(16)   "skipped" if it was skipped for its tags
+//	                             (see Schema.Tag), "reverted" once
+//	                             Schema.Rollback or Schema.DownOne has
+//	                             reverted it, otherwise NULL
+//
+// The table is created when needed, and missing columns are added to a table
+// created by an older version of migrate. Rows are never deleted, so that the
+// table keeps the whole record: installing a reverted version again adds a
+// new row for it.
+func WithHistory() Option {
+	return func(s *Schema) {
+		s.history = true
+	}
+}
+
+// WithHistoryTable sets the name of the history table, which defaults to
+// migration_history, and implies WithHistory. As the name is interpolated
+// into SQL, anything but letters, digits and underscores is rejected, by
+// Validate and by every method that reads or writes the table.
+func WithHistoryTable(name string) Option {
+	return func(s *Schema) {
+		s.history = true
+		s.historyName = name
+	}
+}
+
+// WithAppliedBy records who applied each migration in the applied_by column of
+// the history table, and implies WithHistory. f is called for every migration
+// recorded; if it is nil, the identity is USER@HOST, the database session user
+// and os.Hostname, leaving out whichever of them can't be determined.
+func WithAppliedBy(f func() string) Option {
+	return func(s *Schema) {
+		s.history = true
+		s.appliedBy = f
+		s.recordAppliedBy = true
+	}
+}
+
+func (s *Schema) historyTable() string {
+	if s.historyName == "" {
+		return defaultHistoryTable
+	}
+
+	return s.historyName
+}
+
+// tableName matches the table (and database) names accepted by options.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkHistoryTable returns an error if the name set with WithHistoryTable
+// isn't one that tableName accepts.
+func (s *Schema) checkHistoryTable() error {
+	if !tableName.MatchString(s.historyTable()) {
+		return fmt.Errorf("migrate: invalid history table name %q", s.historyTable())
+	}
+
+	return nil
+}
+
+// timestampType returns the column type migrate's tables use for timestamps.
+func (s *Schema) timestampType() string {
+	switch s.getDialect().(type) {
+	case mysqlDialect:
+		return "DATETIME"
+
+	case sqlServerDialect:
+		return "DATETIME2"
+	}
+
+	return "TIMESTAMP"
+}
+
+func (s *Schema) createHistoryTable() string {
+	return "CREATE TABLE " + s.historyTable() + " (version INT NOT NULL, name VARCHAR(255) NOT NULL, applied_at " + s.timestampType() + " NOT NULL, duration_ms BIGINT NULL, rows_affected BIGINT NULL, applied_by VARCHAR(255) NULL, description VARCHAR(255) NULL, checksum VARCHAR(64) NULL, status VARCHAR(16) NULL)"
+}
+
+// The statuses of history rows other than those of applied migrations.
+const (
+	historySkipped  = "skipped"
+	historyReverted = "reverted"
+)
+
+// ensureHistoryTable creates the history table if history is enabled and it
+// doesn't exist yet.
+func (s *Schema) ensureHistoryTable(ctx context.Context, q Querier) error {
+	if !s.history {
+		return nil
+	}
+
+	if er := s.checkHistoryTable(); er != nil {
+		return er
+	}
+
+	exists, er := s.getDialect().TableExists(ctx, q, s.historyTable())
+	if er != nil {
+		return er
+	}
+
+	if !exists {
+		_, er = q.ExecContext(ctx, s.createHistoryTable())
+		return er
+	}
+
+	for _, column := range []string{"duration_ms", "rows_affected"} {
+		if er := s.ensureColumn(ctx, q, s.historyTable(), column, "BIGINT NULL"); er != nil {
+			return er
+		}
+	}
+
+	for _, column := range []string{"applied_by", "description"} {
+		if er := s.ensureColumn(ctx, q, s.historyTable(), column, "VARCHAR(255) NULL"); er != nil {
+			return er
+		}
+	}
+
+	if er := s.ensureColumn(ctx, q, s.historyTable(), "checksum", "VARCHAR(64) NULL"); er != nil {
+		return er
+	}
+
+	return s.ensureColumn(ctx, q, s.historyTable(), "status", "VARCHAR(16) NULL")
+}
+
+// recordHistory adds the history row for a migration applied in tx.
+func (s *Schema) recordHistory(ctx context.Context, tx *sql.Tx, a AppliedMigration) error {
+	return s.insertHistory(ctx, tx, a, "")
+}
+
+// recordSkipped adds the history row for a migration skipped for its tags.
+func (s *Schema) recordSkipped(ctx context.Context, tx *sql.Tx, m migration) error {
+	return s.insertHistory(ctx, tx, AppliedMigration{
+		Version:      m.minVersion,
+		Name:         m.name,
+		Description:  m.description,
+		RowsAffected: -1,
+	}, historySkipped)
+}
+
+func (s *Schema) insertHistory(ctx context.Context, tx *sql.Tx, a AppliedMigration, status string) error {
+	if !s.history {
+		return nil
+	}
+
+	duration := sql.NullInt64{Int64: a.Duration.Milliseconds(), Valid: status == ""}
+	rows := sql.NullInt64{Int64: a.RowsAffected, Valid: a.RowsAffected >= 0}
+
+	var by sql.NullString
+	if s.recordAppliedBy {
+		by = sql.NullString{String: s.identity(ctx, tx), Valid: true}
+	}
+
+	description := sql.NullString{String: a.Description, Valid: a.Description != ""}
+	checksum := sql.NullString{String: a.Checksum, Valid: a.Checksum != ""}
+	st := sql.NullString{String: status, Valid: status != ""}
+
+	d := s.getDialect()
+	_, er := s.executor(tx).ExecContext(ctx, "INSERT INTO "+s.historyTable()+"(version, name, applied_at, duration_ms, rows_affected, applied_by, description, checksum, status) VALUES("+d.Placeholder(1)+", "+d.Placeholder(2)+", "+d.Placeholder(3)+", "+d.Placeholder(4)+", "+d.Placeholder(5)+", "+d.Placeholder(6)+", "+d.Placeholder(7)+", "+d.Placeholder(8)+", "+d.Placeholder(9)+")", a.Version, a.Name, s.now(), duration, rows, by, description, checksum, st)
+	return er
+}
+
+// identity returns the applied_by value for a migration applied in tx.
+func (s *Schema) identity(ctx context.Context, tx *sql.Tx) string {
+	if s.appliedBy != nil {
+		return s.appliedBy()
+	}
+
+	query := "SELECT CURRENT_USER"
+	switch s.getDialect().(type) {
+	case mysqlDialect:
+		query = "SELECT CURRENT_USER()"
+
+	case sqlServerDialect:
+		query = "SELECT SUSER_SNAME()"
+
+	case sqliteDialect:
+		query = ""
+	}
+
+	// Not every database knows its session user (SQLite doesn't); the
+	// identity is then just the hostname.
+	var user sql.NullString
+	if query != "" {
+		tx.QueryRowContext(ctx, query).Scan(&user)
+	}
+
+	host, _ := os.Hostname()
+	switch {
+	case user.String == "":
+		return host
+
+	case host == "":
+		return user.String
+	}
+
+	return user.String + "@" + host
+}
+
+// revertHistory marks the history rows of the versions above targetVersion as
+// reverted.
+func (s *Schema) revertHistory(ctx context.Context, tx *sql.Tx, targetVersion int) error {
+	if !s.history {
+		return nil
+	}
+
+	d := s.getDialect()
+	_, er := s.executor(tx).ExecContext(ctx, "UPDATE "+s.historyTable()+" SET status = "+d.Placeholder(1)+" WHERE version > "+d.Placeholder(2), historyReverted, targetVersion)
+	return er
+}
+
+// notReverted is the condition matching the history rows of migrations that
+// haven't been reverted.
+const notReverted = "(status IS NULL OR status <> '" + historyReverted + "')"
+
+// historyVersions returns the distinct versions above version that have a
+// history row that hasn't been reverted.
+func (s *Schema) historyVersions(ctx context.Context, q Querier, version int) (map[int]bool, error) {
+	rows, er := q.QueryContext(ctx, "SELECT DISTINCT version FROM "+s.historyTable()+" WHERE version > "+s.getDialect().Placeholder(1)+" AND "+notReverted, version)
+	if er != nil {
+		return nil, er
+	}
+	defer rows.Close()
+
+	versions := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if er := rows.Scan(&v); er != nil {
+			return nil, er
+		}
+
+		versions[v] = true
+	}
+
+	return versions, rows.Err()
+}
+
+// ExportHistory writes the history table (see WithHistory) to w as CSV, with a
+// header row and then one row per migration applied, in the order applied:
+//
+//	version,name,applied_at,duration_ms
+//
+// applied_at is in RFC 3339 format if the driver returns it as a time, and
+// duration_ms is empty if it wasn't recorded, as for a migration skipped for
+// its tags. If there is no history table, only the header is written.
+func (s *Schema) ExportHistory(db *sql.DB, w io.Writer) error {
+	s = s.forDB(db)
+	if er := s.checkHistoryTable(); er != nil {
+		return er
+	}
+
+	ctx := context.Background()
+	out := csv.NewWriter(w)
+
+	if er := out.Write([]string{"version", "name", "applied_at", "duration_ms"}); er != nil {
+		return er
+	}
+
+	exists, er := s.getDialect().TableExists(ctx, db, s.historyTable())
+	if er != nil {
+		return er
+	}
+
+	if exists {
+		if er := s.exportHistory(ctx, db, out); er != nil {
+			return er
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+func (s *Schema) exportHistory(ctx context.Context, db *sql.DB, out *csv.Writer) error {
+	rows, er := db.QueryContext(ctx, "SELECT version, name, applied_at, duration_ms FROM "+s.historyTable()+" ORDER BY applied_at, version")
+	if er != nil {
+		return er
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var name string
+		var appliedAt interface{}
+		var duration sql.NullInt64
+
+		if er := rows.Scan(&version, &name, &appliedAt, &duration); er != nil {
+			return er
+		}
+
+		var ms string
+		if duration.Valid {
+			ms = strconv.FormatInt(duration.Int64, 10)
+		}
+
+		if er := out.Write([]string{strconv.Itoa(version), name, formatTime(appliedAt, time.RFC3339Nano), ms}); er != nil {
+			return er
+		}
+	}
+
+	return rows.Err()
+}
+
+// formatTime formats a timestamp scanned from the history table, which drivers
+// return as a time.Time or as text, with layout.
+func formatTime(value interface{}, layout string) string {
+	switch t := value.(type) {
+	case time.Time:
+		return t.Format(layout)
+
+	case []byte:
+		return string(t)
+
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
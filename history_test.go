@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAppliedBy(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithAppliedBy(nil))
+	s.Update(1, func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var by string
+	if er := db.QueryRow("SELECT applied_by FROM migration_history").Scan(&by); er != nil || by == "" {
+		t.Fatal(by, er)
+	}
+}
+
+func TestHistoryOrder(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.Update(1, func(v int, tx *sql.Tx) error { return nil })
+	s.Update(2, func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.CheckHistoryOrder(db); er != nil {
+		t.Fatal(er)
+	}
+	db.Exec("UPDATE migration_history SET applied_at = '2000-01-01' WHERE version = 2")
+	var oe *OrderInversionError
+	if er := s.CheckHistoryOrder(db); !errors.As(er, &oe) || oe.Pairs[0] != [2]int{2, 1} {
+		t.Fatal(er)
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	var b strings.Builder
+	if er := s.ExportHistory(db, &b); er != nil || b.String() != "version,name,applied_at,duration_ms\n" {
+		t.Fatal(b.String(), er)
+	}
+	s.Configure(WithHistory())
+	s.Update(1, func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	b.Reset()
+	if er := s.ExportHistory(db, &b); er != nil || strings.Count(b.String(), "\n") != 2 {
+		t.Fatal(b.String(), er)
+	}
+}
+
+func TestDescription(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.UpdateNamed(1, "users", "Adds the users table", func(v int, tx *sql.Tx) error { return nil })
+	p, er := s.Plan(db, 1)
+	if er != nil || p.Migrations[0].Description != "Adds the users table" {
+		t.Fatal(p, er)
+	}
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var d string
+	if er := db.QueryRow("SELECT description FROM migration_history").Scan(&d); er != nil || d != "Adds the users table" {
+		t.Fatal(d, er)
+	}
+}
+
+func TestHistoryTableName(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistoryTable("h; DROP TABLE x"))
+	s.UpdateSQL(1, "CREATE TABLE hn(x INT)")
+	var b strings.Builder
+	for name, er := range map[string]error{
+		"Validate":          s.Validate(),
+		"ValidateDB":        s.ValidateDB(db),
+		"ExportHistory":     s.ExportHistory(db, &b),
+		"CheckHistoryOrder": s.CheckHistoryOrder(db),
+		"Install":           s.Install(db, 1),
+	} {
+		if er == nil || !strings.Contains(er.Error(), "invalid history table name") {
+			t.Errorf("%s: %v", name, er)
+		}
+	}
+	if _, er := s.Status(db); er == nil {
+		t.Error("Status accepted the name")
+	}
+}
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks is notified as Install runs each migration, e.g. to log it through a
+// structured logger or to record how long it took as a metric. The methods are
+// called synchronously, within the migration transaction (around the closure,
+// for UpdateNoTx migrations), so they should return quickly. AfterMigration is
+// called before that transaction commits: a migration reported to it may still
+// be rolled back by a later failure, which is reported to OnError. Migrations
+// that are skipped aren't reported.
+type Hooks interface {
+	BeforeMigration(ctx context.Context, e HookEvent)
+	AfterMigration(ctx context.Context, e HookEvent)
+	OnError(ctx context.Context, e HookEvent, er error)
+}
+
+// HookEvent describes the migration a Hooks method is called for.
+type HookEvent struct {
+	Version     int
+	Name        string
+	Description string
+
+	// Current is the database's version before the migration ran, and Target
+	// the version Install was asked to migrate to (for Schema.Step, that of
+	// the migration itself).
+	Current, Target int
+
+	// Duration is how long the migration ran; it is zero for
+	// BeforeMigration.
+	Duration time.Duration
+}
+
+// WithHooks sets the Hooks that Install reports each migration to.
+func WithHooks(h Hooks) Option {
+	return func(s *Schema) {
+		s.hooks = h
+	}
+}
+
+func (s *Schema) hookEvent(m migration, version int, res *Result) HookEvent {
+	target := res.target
+	if target == 0 {
+		target = m.minVersion
+	}
+
+	return HookEvent{
+		Version:     m.minVersion,
+		Name:        m.name,
+		Description: m.description,
+		Current:     version,
+		Target:      target,
+	}
+}
+
+// beforeMigration calls the BeforeMigration hook, if there is one, and returns
+// a function that, given the migration's outcome, calls AfterMigration or
+// OnError.
+func (s *Schema) beforeMigration(ctx context.Context, m migration, version int, res *Result) func(time.Duration, error) {
+	if s.hooks == nil {
+		return func(time.Duration, error) {}
+	}
+
+	e := s.hookEvent(m, version, res)
+	s.hooks.BeforeMigration(ctx, e)
+
+	return func(d time.Duration, er error) {
+		e.Duration = d
+		if er != nil {
+			s.hooks.OnError(ctx, e, er)
+
+		} else {
+			s.hooks.AfterMigration(ctx, e)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type recHooks struct{ log []string }
+
+func (h *recHooks) BeforeMigration(ctx context.Context, e HookEvent) {
+	h.log = append(h.log, fmt.Sprintf("before %d %d->%d", e.Version, e.Current, e.Target))
+}
+
+func (h *recHooks) AfterMigration(ctx context.Context, e HookEvent) {
+	h.log = append(h.log, fmt.Sprintf("after %d", e.Version))
+}
+
+func (h *recHooks) OnError(ctx context.Context, e HookEvent, er error) {
+	h.log = append(h.log, fmt.Sprintf("error %d", e.Version))
+}
+
+func TestHooks(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	h := &recHooks{}
+	s.Configure(WithHooks(h))
+	s.UpdateSQL(1, "CREATE TABLE hk(x INT)")
+	s.Update(2, func(int, *sql.Tx) error { return errors.New("boom") })
+	if er := s.Install(db, 2); er == nil {
+		t.Fatal("expected error")
+	}
+	if got := strings.Join(h.log, ","); got != "before 1 0->2,after 1,before 2 0->2,error 2" {
+		t.Fatal(got)
+	}
+}
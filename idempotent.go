@@ -0,0 +1,118 @@
+package migrate
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// noRewrite is the marker that, anywhere in a migration file, disables
+// WithIdempotentDDL for that file.
+const noRewrite = "-- migrate:no-rewrite"
+
+// WithIdempotentDDL enables best-effort rewriting of the statements in
+// migrations loaded by Schema.AddDir, registered with Schema.UpdateSQL or
+// fetched by a Schema.AddSource source into their idempotent forms, so that
+// re-running a migration that failed part-way on an RDBMS without
+// transactional DDL (such as MySQL) doesn't trip over the objects it already
+// created or dropped. The rewrites are:
+//
+//	CREATE TABLE t ...             -> CREATE TABLE IF NOT EXISTS t ...
+//	DROP TABLE t                   -> DROP TABLE IF EXISTS t
+//	CREATE [UNIQUE] INDEX i ...    -> CREATE [UNIQUE] INDEX IF NOT EXISTS i ...
+//	ALTER TABLE t ADD COLUMN c ... -> ALTER TABLE t ADD COLUMN IF NOT EXISTS c ...
+//
+// Only the forms the Dialect, set or detected, supports are applied: MySQL gets
+// the first two, SQL Server only the DROP TABLE rewrite, SQLite the first
+// three, and Postgres and the generic dialect all four. A statement is only
+// rewritten if it begins, after any comments, with one of these forms and
+// doesn't already say IF [NOT] EXISTS; an ALTER TABLE adding more than one
+// column is left alone.
+// Note that an idempotent CREATE TABLE won't fix up a table created with a
+// different definition. A file (or query) containing the line
+// "-- migrate:no-rewrite" is run exactly as written.
+func WithIdempotentDDL() Option {
+	return func(s *Schema) {
+		s.idempotentDDL = true
+	}
+}
+
+type ddlRewrite struct {
+	// pattern matches the statement's leading keywords; the IF [NOT] EXISTS
+	// clause is inserted after them.
+	pattern *regexp.Regexp
+	clause  string
+}
+
+var (
+	createTableRewrite = ddlRewrite{regexp.MustCompile(`(?i)^CREATE\s+TABLE\s+`), "IF NOT EXISTS "}
+	dropTableRewrite   = ddlRewrite{regexp.MustCompile(`(?i)^DROP\s+TABLE\s+`), "IF EXISTS "}
+	createIndexRewrite = ddlRewrite{regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s+`), "IF NOT EXISTS "}
+	addColumnRewrite   = ddlRewrite{regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+\S+\s+ADD\s+COLUMN\s+`), "IF NOT EXISTS "}
+
+	// multipleAdds matches a further ADD clause in an ALTER TABLE.
+	multipleAdds = regexp.MustCompile(`(?i),\s*ADD\s`)
+
+	// leadingComments matches the whitespace and comments, which splitting
+	// leaves attached, before a statement's first keyword.
+	leadingComments = regexp.MustCompile(`^(?:\s+|--[^\n]*(?:\n|$)|/\*(?s:.*?)\*/)*`)
+)
+
+// ddlRewrites returns the rewrites supported by the Schema's dialect.
+func (s *Schema) ddlRewrites() []ddlRewrite {
+	switch s.getDialect().(type) {
+	case mysqlDialect:
+		return []ddlRewrite{createTableRewrite, dropTableRewrite}
+
+	case sqlServerDialect:
+		return []ddlRewrite{dropTableRewrite}
+
+	case sqliteDialect:
+		return []ddlRewrite{createTableRewrite, dropTableRewrite, createIndexRewrite}
+	}
+
+	return []ddlRewrite{createTableRewrite, dropTableRewrite, createIndexRewrite, addColumnRewrite}
+}
+
+// rewriteIdempotent returns statement rewritten into its idempotent form, or
+// unchanged if no rewrite applies.
+func rewriteIdempotent(statement string, rewrites []ddlRewrite) string {
+	start := len(leadingComments.FindString(statement))
+
+	for _, r := range rewrites {
+		loc := r.pattern.FindStringIndex(statement[start:])
+		if loc == nil {
+			continue
+		}
+
+		end := start + loc[1]
+		rest := statement[end:]
+		if strings.HasPrefix(strings.ToUpper(rest), "IF ") {
+			return statement
+		}
+
+		if r == addColumnRewrite && multipleAdds.MatchString(rest) {
+			return statement
+		}
+
+		return statement[:end] + r.clause + rest
+	}
+
+	return statement
+}
+
+// AlreadyApplied registers a predicate that reports whether the effects of the
+// migrations registered with minVersion are already present, e.g. by checking
+// for the table or column they create. It is consulted whenever those
+// migrations are about to run, and if it reports true they are skipped (and
+// listed in Result.Skipped) while the version still advances past them. This
+// lets a retry on an RDBMS without transactional DDL step over migrations that
+// an earlier, failed run already got through, even though the version was
+// never stamped.
+func (s *Schema) AlreadyApplied(minVersion int, exists func(*sql.Tx) (bool, error)) {
+	if s.exists == nil {
+		s.exists = make(map[int]func(*sql.Tx) (bool, error))
+	}
+
+	s.exists[minVersion] = exists
+}
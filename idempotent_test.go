@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRewriteIdempotent(t *testing.T) {
+	for _, c := range []struct {
+		dialect  Dialect
+		in, want string
+	}{
+		{nil, "CREATE TABLE t(x INT)", "CREATE TABLE IF NOT EXISTS t(x INT)"},
+		{nil, "create  table t(x INT)", "create  table IF NOT EXISTS t(x INT)"},
+		{nil, "CREATE TABLE IF NOT EXISTS t(x INT)", "CREATE TABLE IF NOT EXISTS t(x INT)"},
+		{nil, "DROP TABLE t", "DROP TABLE IF EXISTS t"},
+		{nil, "DROP TABLE IF EXISTS t", "DROP TABLE IF EXISTS t"},
+		{nil, "CREATE INDEX i ON t(x)", "CREATE INDEX IF NOT EXISTS i ON t(x)"},
+		{nil, "CREATE UNIQUE INDEX i ON t(x)", "CREATE UNIQUE INDEX IF NOT EXISTS i ON t(x)"},
+		{nil, "ALTER TABLE t ADD COLUMN y INT", "ALTER TABLE t ADD COLUMN IF NOT EXISTS y INT"},
+		{nil, "ALTER TABLE t ADD COLUMN y INT, ADD z INT", "ALTER TABLE t ADD COLUMN y INT, ADD z INT"},
+		{nil, "INSERT INTO t VALUES(1)", "INSERT INTO t VALUES(1)"},
+		{nil, "-- users\nCREATE TABLE t(x INT)", "-- users\nCREATE TABLE IF NOT EXISTS t(x INT)"},
+		{nil, "\n\n  -- a\n-- b\n  DROP TABLE t", "\n\n  -- a\n-- b\n  DROP TABLE IF EXISTS t"},
+		{nil, "/* tables\n */ CREATE TABLE t(x INT)", "/* tables\n */ CREATE TABLE IF NOT EXISTS t(x INT)"},
+		{nil, "-- CREATE TABLE t(x INT)\nINSERT INTO t VALUES(1)", "-- CREATE TABLE t(x INT)\nINSERT INTO t VALUES(1)"},
+		{nil, "-- only a comment", "-- only a comment"},
+		{DialectMySQL, "CREATE TABLE t(x INT)", "CREATE TABLE IF NOT EXISTS t(x INT)"},
+		{DialectMySQL, "CREATE INDEX i ON t(x)", "CREATE INDEX i ON t(x)"},
+		{DialectMySQL, "ALTER TABLE t ADD COLUMN y INT", "ALTER TABLE t ADD COLUMN y INT"},
+		{DialectSQLServer, "CREATE TABLE t(x INT)", "CREATE TABLE t(x INT)"},
+		{DialectSQLServer, "DROP TABLE t", "DROP TABLE IF EXISTS t"},
+		{DialectSQLite, "CREATE INDEX i ON t(x)", "CREATE INDEX IF NOT EXISTS i ON t(x)"},
+		{DialectSQLite, "ALTER TABLE t ADD COLUMN y INT", "ALTER TABLE t ADD COLUMN y INT"},
+	} {
+		var s Schema
+		if c.dialect != nil {
+			s.SetDialect(c.dialect)
+		}
+		if got := rewriteIdempotent(c.in, s.ddlRewrites()); got != c.want {
+			t.Errorf("%T %q: got %q, want %q", c.dialect, c.in, got, c.want)
+		}
+	}
+}
+
+func TestIdempotentDDL(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE it(x INT)")
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithIdempotentDDL())
+	s.UpdateSQL(1, "-- the table\nCREATE TABLE it(x INT);\n\n-- and its index\nCREATE INDEX iti ON it(x)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+
+	var r Schema
+	r.SetDialect(DialectSQLite)
+	r.Configure(WithIdempotentDDL())
+	r.UpdateSQL(1, noRewrite+"\nCREATE TABLE it(x INT)")
+	db = openDB(t)
+	db.Exec("CREATE TABLE it(x INT)")
+	if er := r.Install(db, 1); er == nil {
+		t.Fatal("no-rewrite marker ignored")
+	}
+}
+
+func TestIdempotentDDLDetectedDialect(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE dd(x INT)")
+	fsys := fstest.MapFS{
+		"1_dd.up.sql": {Data: []byte("CREATE TABLE dd(x INT);\nALTER TABLE dd ADD COLUMN y INT")},
+	}
+	var s Schema
+	s.Configure(WithIdempotentDDL())
+	if er := s.AddDir(fsys, "."); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT y FROM dd"); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestAlreadyApplied(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE aa2(x INT)")
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE aa1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE aa2(x INT)")
+	present := func(table string) func(*sql.Tx) (bool, error) {
+		return func(tx *sql.Tx) (bool, error) {
+			var n int
+			er := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", table).Scan(&n)
+			return n == 1, er
+		}
+	}
+	s.AlreadyApplied(1, present("aa1"))
+	s.AlreadyApplied(2, present("aa2"))
+	res, er := s.InstallResult(db, 2)
+	if er != nil || len(res.Applied) != 1 || res.Applied[0].Version != 1 || len(res.Skipped) != 1 || res.Skipped[0] != 2 || res.To != 2 {
+		t.Fatal(res, er)
+	}
+}
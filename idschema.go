@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// idVersionTable is the name of the table holding an IDSchema's version.
+const idVersionTable = "version_id"
+
+// IDSchema is the counterpart of Schema for migrations identified by string
+// IDs, such as the timestamps (20240115093000) used by Rails and Django,
+// rather than by integer versions; IDs sort lexicographically, so timestamps
+// must be of a fixed width. Its version table, version_id, holds the ID of the
+// last migration applied, and Install applies every migration whose ID sorts
+// after it, in ID order, within a single transaction.
+type IDSchema struct {
+	migrations []idMigration
+	dialect    Dialect
+}
+
+type idMigration struct {
+	id string
+	up func(*sql.Tx) error
+}
+
+// SetDialect sets the Dialect used for the version table bookkeeping. Passing
+// nil restores the default, which is detected from the database's driver.
+func (s *IDSchema) SetDialect(d Dialect) {
+	s.dialect = d
+}
+
+// Update registers the migration with the given ID. Registration order doesn't
+// matter, but IDs must be unique.
+func (s *IDSchema) Update(id string, f func(*sql.Tx) error) {
+	s.migrations = append(s.migrations, idMigration{id: id, up: f})
+}
+
+// Install applies the migrations whose IDs sort after the one recorded in the
+// database, creating the version table if needed, and records the ID of the
+// last one applied.
+func (s *IDSchema) Install(db *sql.DB) error {
+	ctx := context.Background()
+
+	migrations := append([]idMigration(nil), s.migrations...)
+	sort.SliceStable(migrations, func(i, j int) bool { return migrations[i].id < migrations[j].id })
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].id == migrations[i-1].id {
+			return fmt.Errorf("migrate: duplicate migration ID %q", migrations[i].id)
+		}
+	}
+
+	d := (&Schema{dialect: s.dialect}).forDB(db).getDialect()
+
+	conn, er := db.Conn(ctx)
+	if er != nil {
+		return er
+	}
+	defer conn.Close()
+
+	if er := d.Lock(ctx, conn, idVersionTable); er != nil {
+		return er
+	}
+	defer d.Unlock(ctx, conn, idVersionTable)
+
+	if er := s.bootstrap(ctx, conn, d); er != nil {
+		return er
+	}
+
+	tx, er := conn.BeginTx(ctx, nil)
+	if er != nil {
+		return er
+	}
+	defer tx.Rollback()
+
+	var current string
+	if er := tx.QueryRowContext(ctx, "SELECT id FROM "+idVersionTable).Scan(&current); er != nil {
+		return er
+	}
+
+	last := current
+	for _, m := range migrations {
+		if m.id <= current {
+			continue
+		}
+
+		if er := m.up(tx); er != nil {
+			return fmt.Errorf("migrate: migration %s: %w", m.id, er)
+		}
+
+		last = m.id
+	}
+
+	if last == current {
+		return nil
+	}
+
+	if _, er := tx.ExecContext(ctx, "UPDATE "+idVersionTable+" SET id = "+d.Placeholder(1), last); er != nil {
+		return er
+	}
+
+	return tx.Commit()
+}
+
+// Version returns the ID of the last migration applied to db, or "" if none
+// has been.
+func (s *IDSchema) Version(db *sql.DB) (string, error) {
+	var id string
+	er := db.QueryRowContext(context.Background(), "SELECT id FROM "+idVersionTable).Scan(&id)
+	if er == sql.ErrNoRows || IsUndefinedObject(er) {
+		return "", nil
+	}
+
+	return id, er
+}
+
+// bootstrap creates and seeds the version table if it doesn't exist yet.
+func (s *IDSchema) bootstrap(ctx context.Context, db Querier, d Dialect) error {
+	exists, er := d.TableExists(ctx, db, idVersionTable)
+	if er != nil || exists {
+		return er
+	}
+
+	if _, er := db.ExecContext(ctx, "CREATE TABLE "+idVersionTable+" (id VARCHAR(255) NOT NULL, "+singleRow+")"); er != nil {
+		return er
+	}
+
+	_, er = db.ExecContext(ctx, "INSERT INTO "+idVersionTable+"(id) VALUES("+d.Placeholder(1)+")", "")
+	return er
+}
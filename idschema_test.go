@@ -0,0 +1,23 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestIDSchema(t *testing.T) {
+	db := openDB(t)
+	var s IDSchema
+	n := 0
+	s.Update("20240102", func(tx *sql.Tx) error { n++; return nil })
+	s.Update("20240101", func(tx *sql.Tx) error { n++; return nil })
+	if er := s.Install(db); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db); er != nil {
+		t.Fatal(er)
+	}
+	if id, _ := s.Version(db); id != "20240102" || n != 2 {
+		t.Fatal(id, n)
+	}
+}
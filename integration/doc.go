@@ -0,0 +1,4 @@
+// Package integration holds the tests that run migrate against real database
+// servers. It is a separate module so that the drivers they need don't become
+// dependencies of the library; see integration_test.go for how to run them.
+package integration
@@ -0,0 +1,144 @@
+//go:build postgres || mysql || sqlserver
+
+package integration
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lye/migrate"
+)
+
+// The integration tests run against a real server, selected with a build tag
+// and pointed at by an environment variable, e.g.
+//
+//	MIGRATE_POSTGRES_DSN=postgres://... go test -tags postgres
+//
+// They drop and recreate the tables they use, so the database should be a
+// scratch one.
+
+func openIntegrationDB(t *testing.T, driver, env string) *sql.DB {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skip(env + " is not set")
+	}
+
+	db, er := sql.Open(driver, dsn)
+	if er != nil {
+		t.Fatal(er)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func resetIntegrationDB(t *testing.T, db *sql.DB) {
+	for _, table := range []string{"version", "migration_history", "migrate_lock", "it_a", "it_b", "it_u"} {
+		if _, er := db.Exec("DROP TABLE IF EXISTS " + table); er != nil {
+			t.Fatal(er)
+		}
+	}
+}
+
+func testIntegration(t *testing.T, db *sql.DB, dialect migrate.Dialect) {
+	t.Run("Install", func(t *testing.T) {
+		resetIntegrationDB(t, db)
+		var s migrate.Schema
+		s.SetDialect(dialect)
+		s.Configure(migrate.WithHistory())
+		s.UpdateSQL(1, "CREATE TABLE it_a(x INT)")
+		s.UpdateSQL(2, "CREATE TABLE it_b(x INT)")
+		if er := s.Install(db, 2); er != nil {
+			t.Fatal(er)
+		}
+		if v, er := migrate.Version(db); er != nil || v != 2 {
+			t.Fatal(v, er)
+		}
+		res, er := s.InstallResult(db, 2)
+		if er != nil || !res.UpToDate {
+			t.Fatal(res, er)
+		}
+		st, er := s.Status(db)
+		if er != nil || len(st) != 2 || !st[0].Applied || !st[1].Applied {
+			t.Fatal(st, er)
+		}
+	})
+
+	t.Run("TxAuto", func(t *testing.T) {
+		resetIntegrationDB(t, db)
+		var s migrate.Schema
+		s.SetDialect(dialect)
+		s.UpdateSQL(1, "CREATE TABLE it_a(x INT)")
+		s.Update(2, func(v int, tx *sql.Tx) error {
+			if _, er := tx.Exec("CREATE TABLE it_b(x INT)"); er != nil {
+				return er
+			}
+			return errors.New("boom")
+		})
+		if er := s.Install(db, 2); er == nil {
+			t.Fatal("expected failure")
+		}
+
+		// With transactional DDL everything is rolled back; without it each
+		// migration is stamped as it completes.
+		want := 1
+		if dialect.TransactionalDDL() {
+			want = 0
+		}
+		if v, er := migrate.Version(db); er != nil || v != want {
+			t.Fatal(v, er)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		resetIntegrationDB(t, db)
+		var runs int32
+
+		// Each installer has its own Schema, as separate processes would.
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var s migrate.Schema
+				s.SetDialect(dialect)
+				s.Update(1, func(v int, tx *sql.Tx) error {
+					atomic.AddInt32(&runs, 1)
+					_, er := tx.Exec("CREATE TABLE it_a(x INT)")
+					return er
+				})
+				errs[i] = s.Install(db, 1)
+			}(i)
+		}
+		wg.Wait()
+		for _, er := range errs {
+			if er != nil {
+				t.Fatal(er)
+			}
+		}
+		if runs != 1 {
+			t.Fatal(runs)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		resetIntegrationDB(t, db)
+		if _, er := db.Exec("SELECT x FROM it_a"); !migrate.IsUndefinedObject(er) {
+			t.Fatal(er)
+		}
+		if _, er := db.Exec("CREATE TABLE it_u(x INT PRIMARY KEY)"); er != nil {
+			t.Fatal(er)
+		}
+		if _, er := db.Exec("INSERT INTO it_u VALUES(1)"); er != nil {
+			t.Fatal(er)
+		}
+		if _, er := db.Exec("INSERT INTO it_u VALUES(1)"); !migrate.IsUniqueViolation(er) {
+			t.Fatal(er)
+		}
+	})
+}
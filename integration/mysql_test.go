@@ -0,0 +1,14 @@
+//go:build mysql
+
+package integration
+
+import (
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/lye/migrate"
+)
+
+func TestMySQL(t *testing.T) {
+	testIntegration(t, openIntegrationDB(t, "mysql", "MIGRATE_MYSQL_DSN"), migrate.DialectMySQL)
+}
@@ -0,0 +1,14 @@
+//go:build postgres
+
+package integration
+
+import (
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/lye/migrate"
+)
+
+func TestPostgres(t *testing.T) {
+	testIntegration(t, openIntegrationDB(t, "postgres", "MIGRATE_POSTGRES_DSN"), migrate.DialectPostgres)
+}
@@ -0,0 +1,14 @@
+//go:build sqlserver
+
+package integration
+
+import (
+	"testing"
+
+	"github.com/lye/migrate"
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+func TestSQLServer(t *testing.T) {
+	testIntegration(t, openIntegrationDB(t, "sqlserver", "MIGRATE_SQLSERVER_DSN"), migrate.DialectSQLServer)
+}
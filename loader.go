@@ -0,0 +1,428 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFile matches the names of the files read by Schema.AddDir, e.g.
+// 0003_add_users.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// streamSize is the size above which AddDir streams a file's statements from
+// fsys as they are executed instead of holding the file in memory.
+const streamSize = 1 << 20
+
+// markerWindow is how far into a streamed file AddDir looks for noRewrite.
+const markerWindow = 64 << 10
+
+// AddDir registers the SQL migrations stored in dir of fsys (which may be an
+// embed.FS). Files are named VERSION_NAME.up.sql, with an optional
+// VERSION_NAME.down.sql holding the matching down migration, e.g.
+// 0003_add_users.up.sql; other files are ignored. Migrations are registered in
+// version order. Each file is split into statements at semicolons (outside of
+// quotes, comments and dollar-quoted strings) and the statements are executed
+// one at a time within the migration transaction. Embedding the directory
+// ships the migrations inside the binary:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	schema.AddDir(migrations, "migrations")
+//
+// Files larger than 1 MiB, such as bulk data loads, aren't held in memory:
+// AddDir only reads them through to checksum them (see WithChecksums and
+// WithPlanHash), and they are opened again when the migration runs, each
+// statement being executed as soon as it has been read, so fsys must remain
+// readable until then. The Migration reported by Schema.Migrations (and by
+// Schema.Plan) for such a file has no SQL, and the "-- migrate:no-rewrite"
+// marker must appear within its first 64 KiB.
+//
+// An up file containing the marker "-- migrate:no-transaction" (within the
+// same 64 KiB) is run outside of any transaction, as described for
+// UpdateNoTx, e.g. for Postgres' CREATE INDEX CONCURRENTLY. Each of its
+// statements then commits on its own. The marker has no effect on down
+// files, which Rollback always runs in its transaction.
+func (s *Schema) AddDir(fsys fs.FS, dir string) error {
+	entries, er := fs.ReadDir(fsys, dir)
+	if er != nil {
+		return er
+	}
+
+	type file struct {
+		name     string
+		up, down *script
+	}
+
+	files := make(map[int]*file)
+
+	for _, entry := range entries {
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil || entry.IsDir() {
+			continue
+		}
+
+		version, er := strconv.Atoi(m[1])
+		if er != nil {
+			return fmt.Errorf("migrate: %s: %w", entry.Name(), er)
+		}
+
+		var h hash.Hash
+		if m[3] == "up" {
+			h = migrationHash(version, m[2])
+		}
+
+		sc, er := loadScript(fsys, path.Join(dir, entry.Name()), entry, h)
+		if er != nil {
+			return er
+		}
+
+		f := files[version]
+		if f == nil {
+			f = &file{name: m[2]}
+			files[version] = f
+
+		} else if f.name != m[2] {
+			return fmt.Errorf("migrate: %s: version %d is already used by %q", entry.Name(), version, f.name)
+		}
+
+		if m[3] == "up" {
+			f.up = sc
+
+		} else {
+			f.down = sc
+		}
+	}
+
+	versions := make([]int, 0, len(files))
+	for version, f := range files {
+		if f.up == nil {
+			return fmt.Errorf("migrate: %s: down migration %d has no up migration", dir, version)
+		}
+
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		f := files[version]
+
+		if f.up.noTx {
+			m := noTxMigration(version, execScriptNoTx(f.up))
+			m.name, m.sql, m.digest = f.name, f.up.text, f.up.digest
+			s.migrations = append(s.migrations, m)
+
+		} else {
+			s.migrations = append(s.migrations, migration{
+				minVersion: version,
+				name:       f.name,
+				sql:        f.up.text,
+				digest:     f.up.digest,
+				up:         execScript(f.up),
+			})
+		}
+
+		if f.down != nil {
+			down := execScript(f.down)
+			s.setDown(version, downMigration{
+				down: func(ctx context.Context, run *Schema, version int, tx *sql.Tx) error {
+					_, er := down(ctx, run, version, tx)
+					return er
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+// MustAddDir is like AddDir, but panics if the migrations can't be loaded. It
+// is meant for registering embedded migrations from package initialization,
+// where a failure is a build mistake rather than a runtime condition.
+func (s *Schema) MustAddDir(fsys fs.FS, dir string) {
+	if er := s.AddDir(fsys, dir); er != nil {
+		panic(er)
+	}
+}
+
+// script is the SQL of a migration, either held in text or, if open is set,
+// streamed from a file, in which case digest is the checksum of the file.
+type script struct {
+	text      string
+	open      func() (io.ReadCloser, error)
+	digest    string
+	noRewrite bool
+	noTx      bool
+}
+
+// loadScript reads the file named name of fsys, unless it is larger than
+// streamSize, in which case only its first markerWindow bytes are kept, to
+// look for noRewrite and noTransaction, and the rest is only read through h
+// (if it isn't nil) for the script's digest.
+func loadScript(fsys fs.FS, name string, entry fs.DirEntry, h hash.Hash) (*script, error) {
+	info, er := entry.Info()
+	if er != nil {
+		return nil, er
+	}
+
+	if info.Size() <= streamSize {
+		contents, er := fs.ReadFile(fsys, name)
+		if er != nil {
+			return nil, er
+		}
+
+		return &script{
+			text:      string(contents),
+			noRewrite: strings.Contains(string(contents), noRewrite),
+			noTx:      strings.Contains(string(contents), noTransaction),
+		}, nil
+	}
+
+	f, er := fsys.Open(name)
+	if er != nil {
+		return nil, er
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if h != nil {
+		r = io.TeeReader(f, h)
+	}
+
+	head, er := io.ReadAll(io.LimitReader(r, markerWindow))
+	if er != nil {
+		return nil, er
+	}
+
+	var digest string
+	if h != nil {
+		if _, er := io.Copy(h, f); er != nil {
+			return nil, er
+		}
+
+		digest = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return &script{
+		open:      func() (io.ReadCloser, error) { return fsys.Open(name) },
+		digest:    digest,
+		noRewrite: strings.Contains(string(head), noRewrite),
+		noTx:      strings.Contains(string(head), noTransaction),
+	}, nil
+}
+
+// execStatements returns a migration closure that runs each statement of query
+// in turn through the running Schema's executor, rewritten according to its
+// WithIdempotentDDL setting and dialect.
+func execStatements(query string) func(context.Context, *Schema, int, *sql.Tx) (int64, error) {
+	return execScript(&script{text: query, noRewrite: strings.Contains(query, noRewrite)})
+}
+
+// execScript is like execStatements, but for sc, which is read anew each time
+// the closure runs.
+func execScript(sc *script) func(context.Context, *Schema, int, *sql.Tx) (int64, error) {
+	return func(ctx context.Context, run *Schema, version int, tx *sql.Tx) (int64, error) {
+		return -1, run.runScript(ctx, sc, run.executor(tx))
+	}
+}
+
+// execScriptNoTx is like execScript, but returns a closure for
+// noTxMigration, which runs sc's statements directly on the connection.
+func execScriptNoTx(sc *script) func(context.Context, *Schema, int, DB) error {
+	return func(ctx context.Context, run *Schema, version int, conn DB) error {
+		return run.runScript(ctx, sc, conn)
+	}
+}
+
+// runScript executes the statements of sc on q, one at a time.
+func (s *Schema) runScript(ctx context.Context, sc *script, q Querier) error {
+	var rewrites []ddlRewrite
+	if !sc.noRewrite && s.idempotentDDL {
+		rewrites = s.ddlRewrites()
+	}
+
+	r := io.ReadCloser(io.NopCloser(strings.NewReader(sc.text)))
+	if sc.open != nil {
+		var er error
+		if r, er = sc.open(); er != nil {
+			return er
+		}
+	}
+	defer r.Close()
+
+	statements := newStatementScanner(r)
+
+	for {
+		statement, er := statements.next()
+		if er == io.EOF {
+			return nil
+		}
+
+		if er != nil {
+			return er
+		}
+
+		if _, er := q.ExecContext(ctx, rewriteIdempotent(statement, rewrites)); er != nil {
+			return fmt.Errorf("%s: %w", snippet(statement), er)
+		}
+	}
+}
+
+// snippet returns statement, quoted and truncated for use in an error message.
+func snippet(statement string) string {
+	const max = 60
+
+	if len(statement) > max {
+		statement = statement[:max] + "..."
+	}
+
+	return strconv.Quote(statement)
+}
+
+// splitStatements splits a script into its individual statements at each
+// semicolon that isn't inside a quoted string or identifier, a comment, or a
+// Postgres dollar-quoted string. Empty statements are dropped.
+func splitStatements(script string) []string {
+	var statements []string
+	sc := newStatementScanner(strings.NewReader(script))
+
+	for {
+		statement, er := sc.next()
+		if er != nil {
+			return statements
+		}
+
+		statements = append(statements, statement)
+	}
+}
+
+// statementScanner reads statements from a script one at a time, splitting it
+// as splitStatements does, so a script of any size can be executed while only
+// holding one statement in memory.
+type statementScanner struct {
+	r         *bufio.Reader
+	statement bytes.Buffer
+}
+
+func newStatementScanner(r io.Reader) *statementScanner {
+	return &statementScanner{r: bufio.NewReader(r)}
+}
+
+// next returns the next non-empty statement, with surrounding whitespace
+// trimmed, or io.EOF once the script is exhausted.
+func (sc *statementScanner) next() (string, error) {
+	for {
+		c, er := sc.r.ReadByte()
+		if er == io.EOF {
+			statement := strings.TrimSpace(sc.statement.String())
+			sc.statement.Reset()
+
+			if statement == "" {
+				return "", io.EOF
+			}
+
+			return statement, nil
+		}
+
+		if er != nil {
+			return "", er
+		}
+
+		if c == ';' {
+			statement := strings.TrimSpace(sc.statement.String())
+			sc.statement.Reset()
+
+			if statement != "" {
+				return statement, nil
+			}
+
+			continue
+		}
+
+		sc.statement.WriteByte(c)
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			er = sc.copyThrough(string(c), 0)
+
+		case c == '-' && sc.peekIs("-"):
+			er = sc.copyThrough("\n", 0)
+
+		case c == '/' && sc.peekIs("*"):
+			// The opening "/*" can't also close the comment.
+			er = sc.copyThrough("*/", 1)
+
+		case c == '$':
+			peek, _ := sc.r.Peek(64)
+			if tag := dollarTag("$" + string(peek)); tag != "" {
+				er = sc.copyThrough(tag, len(tag)-1)
+			}
+		}
+
+		if er != nil && er != io.EOF {
+			return "", er
+		}
+	}
+}
+
+func (sc *statementScanner) peekIs(prefix string) bool {
+	peek, _ := sc.r.Peek(len(prefix))
+	return string(peek) == prefix
+}
+
+// copyThrough copies the next skip bytes into the statement, and then the
+// bytes up to and including the next occurrence of end (or the rest of the
+// script, if end doesn't occur).
+func (sc *statementScanner) copyThrough(end string, skip int) error {
+	start := sc.statement.Len()
+
+	for {
+		c, er := sc.r.ReadByte()
+		if er != nil {
+			return er
+		}
+
+		sc.statement.WriteByte(c)
+
+		if skip > 0 {
+			skip--
+			start++
+			continue
+		}
+
+		if bytes.HasSuffix(sc.statement.Bytes()[start:], []byte(end)) {
+			return nil
+		}
+	}
+}
+
+// dollarTag returns the dollar-quote tag ($$ or $name$) that s starts with, or
+// "" if it doesn't start with one. Positional parameters such as $1 are not
+// tags.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+			continue
+		}
+
+		return ""
+	}
+
+	return ""
+}
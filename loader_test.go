@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAddDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0001_a.up.sql":   {Data: []byte("CREATE TABLE a(x TEXT); INSERT INTO a VALUES('x;y'); -- c;\n")},
+		"m/0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"m/0002_b.up.sql":   {Data: []byte("CREATE TABLE b(x INT);\n/* ; */ CREATE TABLE c(x INT)")},
+	}
+	var s Schema
+	if er := s.AddDir(fsys, "m"); er != nil {
+		t.Fatal(er)
+	}
+	db := openDB(t)
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	var x string
+	if er := db.QueryRow("SELECT x FROM a").Scan(&x); er != nil || x != "x;y" {
+		t.Fatal(x, er)
+	}
+	if got := splitStatements("SELECT $$a;b$$; SELECT $1;"); len(got) != 2 {
+		t.Fatal(got)
+	}
+}
+
+func TestStream(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE big(x TEXT);\n")
+	for b.Len() <= streamSize {
+		b.WriteString("INSERT INTO big VALUES('a;b'); /* ; */ -- ;\n")
+	}
+	fsys := fstest.MapFS{"m/0001_big.up.sql": {Data: []byte(b.String())}}
+	var s Schema
+	if er := s.AddDir(fsys, "m"); er != nil {
+		t.Fatal(er)
+	}
+	if s.migrations[0].sql != "" {
+		t.Fatal("not streamed")
+	}
+	db := openDB(t)
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM big WHERE x = 'a;b'").Scan(&n)
+	if n < 1000 {
+		t.Fatal(n)
+	}
+	for _, c := range []struct {
+		in   string
+		want int
+	}{{"a; b;; c", 3}, {"'x;y'; $$a;b$$; $t$ ; $t$;", 3}, {"/*/;*/ x; -- y;\nz", 2}, {"'unterminated;", 1}, {"  ;  ", 0}} {
+		if got := splitStatements(c.in); len(got) != c.want {
+			t.Fatal(c.in, got)
+		}
+	}
+}
+
+func TestStreamChecksum(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE bigc(x TEXT);\n")
+	for b.Len() <= streamSize {
+		b.WriteString("INSERT INTO bigc VALUES('a');\n")
+	}
+	fsys := fstest.MapFS{"0001_big.up.sql": {Data: []byte(b.String())}}
+	var s Schema
+	s.Configure(WithChecksums())
+	if er := s.AddDir(fsys, "."); er != nil {
+		t.Fatal(er)
+	}
+	if got, want := s.migrations[0].hash(), (Migration{Version: 1, Name: "big", SQL: b.String()}).Hash(); got != want {
+		t.Fatal(got, want)
+	}
+	db := openDB(t)
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+
+	fsys["0001_big.up.sql"].Data = []byte(b.String() + "INSERT INTO bigc VALUES('b');\n")
+	var edited Schema
+	edited.Configure(WithChecksums())
+	if er := edited.AddDir(fsys, "."); er != nil {
+		t.Fatal(er)
+	}
+	var mm *ChecksumMismatchError
+	if er := edited.Install(db, 1); !errors.As(er, &mm) || mm.Version != 1 {
+		t.Fatal(er)
+	}
+}
+
+func TestAddDirNoTx(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	fsys := fstest.MapFS{
+		"1_a.up.sql": {Data: []byte("CREATE TABLE nt(x INT)")},
+		"2_b.up.sql": {Data: []byte("-- migrate:no-transaction\nCREATE INDEX nt_x ON nt(x); CREATE TABLE nt2(y INT)")},
+		"3_c.up.sql": {Data: []byte("CREATE TABLE nt3(x INT)")},
+	}
+	if er := s.AddDir(fsys, "."); er != nil {
+		t.Fatal(er)
+	}
+	res, er := s.InstallResult(db, 3)
+	if er != nil || len(res.Applied) != 3 || res.To != 3 {
+		t.Fatal(er, res)
+	}
+	if _, er := db.Exec("SELECT * FROM nt2"); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestMustAddDir(t *testing.T) {
+	var s Schema
+	s.MustAddDir(fstest.MapFS{"m/0001_a.up.sql": {Data: []byte("CREATE TABLE ma(x INT)")}}, "m")
+	db := openDB(t)
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM ma"); er != nil {
+		t.Fatal(er)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("missing directory accepted")
+		}
+	}()
+	s.MustAddDir(fstest.MapFS{}, "missing")
+}
@@ -0,0 +1,300 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"time"
+)
+
+// ErrLockTimeout is returned when the migration lock could not be acquired
+// within LockStrategy.Timeout.
+var ErrLockTimeout = errors.New("migrate: timed out waiting for migration lock")
+
+// ErrLockHeld is returned under LockStrategy.Skip when another instance holds
+// the migration lock.
+var ErrLockHeld = errors.New("migrate: migration lock is held elsewhere")
+
+// TryLocker may be implemented by a Dialect that can attempt to take its lock
+// without waiting. It is needed for LockStrategy.Poll.
+type TryLocker interface {
+	// TryLock is like Dialect.Lock, but reports false straight away if the
+	// lock is held elsewhere.
+	TryLock(ctx context.Context, conn *sql.Conn, key string) (bool, error)
+}
+
+// LockStrategy controls how the migration lock is acquired under contention.
+type LockStrategy struct {
+	// Timeout bounds how long to wait for the lock before giving up with
+	// ErrLockTimeout. Zero waits indefinitely.
+	Timeout time.Duration
+
+	// Poll makes migrate retry a non-blocking TryLock every PollInterval
+	// (default one second) rather than block in Dialect.Lock. It is ignored
+	// for a Dialect that doesn't implement TryLocker.
+	Poll         bool
+	PollInterval time.Duration
+
+	// Skip makes migrate give up straight away with ErrLockHeld if the lock
+	// is held elsewhere, so that an instance booting alongside the one that
+	// is migrating can carry on without waiting for it. It overrides Poll
+	// and Timeout, and is ignored for a Dialect that doesn't implement
+	// TryLocker.
+	Skip bool
+}
+
+// WithLockStrategy sets how the migration lock is acquired. By default migrate
+// blocks in Dialect.Lock for as long as it takes.
+func WithLockStrategy(strategy LockStrategy) Option {
+	return func(s *Schema) {
+		s.lockStrategy = strategy
+	}
+}
+
+// WithLockKey sets the key identifying the migration lock, which defaults to
+// the name of the version table. Applications that share a database server
+// but keep separate schemas should each set a distinct key (an application
+// identifier, say), so that their migrations neither block each other nor,
+// worse, run under each other's lock. Dialects whose locks are keyed by
+// integer should derive one with AdvisoryLockID.
+func WithLockKey(key string) Option {
+	return func(s *Schema) {
+		s.lockName = key
+	}
+}
+
+// AdvisoryLockID hashes a lock key to the integer identifier used by locks such
+// as Postgres' pg_advisory_lock, so that distinct keys get distinct locks.
+func AdvisoryLockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("migrate:" + key))
+	return int64(h.Sum64())
+}
+
+// lockTable is the name of the table WithLockTable takes locks in.
+const lockTable = "migrate_lock"
+
+// WithLockTable replaces the Dialect's lock with a row in the migrate_lock
+// table, created when needed, for databases that have no locks of their own
+// (those using the generic dialect or DialectSQLite). The lock is taken by
+// inserting the row for the lock key and released by deleting it; while the
+// row exists other instances wait, subject to the LockStrategy (polling every
+// PollInterval). As the row outlives a process that dies while migrating, it
+// then has to be deleted by hand; LockStrategy.Timeout at least turns the wait
+// for it into ErrLockTimeout.
+func WithLockTable() Option {
+	return func(s *Schema) {
+		s.lockRow = true
+	}
+}
+
+// locker is the part of Dialect that takes the migration lock.
+type locker interface {
+	Lock(ctx context.Context, conn *sql.Conn, key string) error
+	Unlock(ctx context.Context, conn *sql.Conn, key string) error
+}
+
+// locker returns the locker configured for s.
+func (s *Schema) locker() locker {
+	if s.lockRow {
+		return tableLocker{s: s}
+	}
+
+	return s.getDialect()
+}
+
+// tableLocker implements WithLockTable.
+type tableLocker struct {
+	s *Schema
+}
+
+func (l tableLocker) Lock(ctx context.Context, conn *sql.Conn, key string) error {
+	interval := l.s.lockStrategy.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for {
+		locked, er := l.TryLock(ctx, conn, key)
+		if locked || er != nil {
+			return er
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (l tableLocker) TryLock(ctx context.Context, conn *sql.Conn, key string) (bool, error) {
+	d := l.s.getDialect()
+
+	exists, er := d.TableExists(ctx, conn, lockTable)
+	if er != nil {
+		return false, er
+	}
+
+	if !exists {
+		// Another instance may create the table first, which is fine.
+		if _, er := conn.ExecContext(ctx, "CREATE TABLE "+lockTable+" (lock_key VARCHAR(255) NOT NULL PRIMARY KEY, locked_at "+l.s.timestampType()+" NOT NULL)"); er != nil {
+			if exists, _ := d.TableExists(ctx, conn, lockTable); !exists {
+				return false, er
+			}
+		}
+	}
+
+	_, er = conn.ExecContext(ctx, "INSERT INTO "+lockTable+"(lock_key, locked_at) VALUES("+d.Placeholder(1)+", "+d.Placeholder(2)+")", key, l.s.now())
+	if er == nil {
+		return true, nil
+	}
+
+	// The insert fails with a unique violation if the lock is held, which
+	// drivers report in their own ways, so look for the row instead.
+	var count int
+	if countEr := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+lockTable+" WHERE lock_key = "+d.Placeholder(1), key).Scan(&count); countEr != nil || count == 0 {
+		return false, er
+	}
+
+	return false, nil
+}
+
+func (l tableLocker) Unlock(ctx context.Context, conn *sql.Conn, key string) error {
+	_, er := conn.ExecContext(ctx, "DELETE FROM "+lockTable+" WHERE lock_key = "+l.s.getDialect().Placeholder(1), key)
+	return er
+}
+
+func (s *Schema) lockKey() string {
+	if s.lockName == "" {
+		return s.stateTable()
+	}
+
+	return s.lockName
+}
+
+// lock acquires the dialect's migration lock on conn according to the
+// configured LockStrategy. If ctx is done before the lock is acquired, ctx's
+// error is returned; if the lock is acquired just as ctx is done, it is
+// released again, so that the caller never goes on to migrate with a dead
+// context.
+func (s *Schema) lock(ctx context.Context, conn *sql.Conn) error {
+	er := s.acquire(ctx, conn)
+	if er == nil && ctx.Err() != nil {
+		s.unlock(conn)
+		return ctx.Err()
+	}
+
+	return er
+}
+
+// unlock releases the migration lock on conn. It doesn't take the migration's
+// context, as the lock must be released even if that has been cancelled:
+// conn goes back to the pool, still holding any session-level lock.
+func (s *Schema) unlock(conn *sql.Conn) error {
+	return s.locker().Unlock(context.Background(), conn, s.lockKey())
+}
+
+func (s *Schema) acquire(parent context.Context, conn *sql.Conn) error {
+	d := s.locker()
+	strategy := s.lockStrategy
+
+	ctx := parent
+	if strategy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, strategy.Timeout)
+		defer cancel()
+	}
+
+	// waitError returns the error to report when waiting for the lock ended
+	// because ctx is done.
+	waitError := func() error {
+		if er := parent.Err(); er != nil {
+			return er
+		}
+
+		return ErrLockTimeout
+	}
+
+	try, ok := d.(TryLocker)
+	if strategy.Skip && ok {
+		locked, er := try.TryLock(parent, conn, s.lockKey())
+		if er == nil && !locked {
+			return ErrLockHeld
+		}
+
+		return er
+	}
+
+	if !strategy.Poll || !ok {
+		er := d.Lock(ctx, conn, s.lockKey())
+		if er != nil && ctx.Err() != nil {
+			return waitError()
+		}
+
+		return er
+	}
+
+	interval := strategy.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for {
+		locked, er := try.TryLock(ctx, conn, s.lockKey())
+		if locked {
+			return nil
+
+		} else if er != nil && ctx.Err() == nil {
+			return er
+		}
+
+		select {
+		case <-ctx.Done():
+			return waitError()
+
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (postgresDialect) TryLock(ctx context.Context, conn *sql.Conn, key string) (bool, error) {
+	var locked bool
+	if er := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", AdvisoryLockID(key)).Scan(&locked); er != nil {
+		return false, er
+	}
+
+	return locked, nil
+}
+
+func (mysqlDialect) TryLock(ctx context.Context, conn *sql.Conn, key string) (bool, error) {
+	var status sql.NullInt64
+	if er := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", "migrate:"+key).Scan(&status); er != nil {
+		return false, er
+	}
+
+	return status.Valid && status.Int64 == 1, nil
+}
+
+func (sqlServerDialect) TryLock(ctx context.Context, conn *sql.Conn, key string) (bool, error) {
+	var status int
+	er := conn.QueryRowContext(ctx, `DECLARE @r INT;
+EXEC @r = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0;
+SELECT @r`, "migrate:"+key).Scan(&status)
+	if er != nil {
+		return false, er
+	}
+
+	// -1 means the request timed out, i.e. the lock is held elsewhere.
+	switch {
+	case status >= 0:
+		return true, nil
+
+	case status == -1:
+		return false, nil
+	}
+
+	return false, ErrLockFailed
+}
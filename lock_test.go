@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockCancelled(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Update(1, func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Update(2, func(v int, tx *sql.Tx) error { t.Fatal("ran"); return nil })
+	if er := s.InstallContext(ctx, db, 2); !errors.Is(er, context.Canceled) {
+		t.Fatal(er)
+	}
+}
+
+func TestLockTable(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithLockTable(), WithLockStrategy(LockStrategy{Timeout: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond}))
+	s.UpdateSQL(1, "CREATE TABLE lt(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM migrate_lock").Scan(&n)
+	if n != 0 {
+		t.Fatal(n)
+	}
+	db.Exec("INSERT INTO migrate_lock VALUES('version', CURRENT_TIMESTAMP)")
+	if er := s.Install(db, 1); !errors.Is(er, ErrLockTimeout) {
+		t.Fatal(er)
+	}
+}
+
+func TestLockSkip(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithLockTable(), WithLockStrategy(LockStrategy{Skip: true}))
+	s.UpdateSQL(1, "CREATE TABLE ls(x INT)")
+	db.Exec("CREATE TABLE migrate_lock (lock_key VARCHAR(255) NOT NULL PRIMARY KEY, locked_at TIMESTAMP NOT NULL)")
+	db.Exec("INSERT INTO migrate_lock VALUES('version', CURRENT_TIMESTAMP)")
+	if er := s.Install(db, 1); !errors.Is(er, ErrLockHeld) {
+		t.Fatal(er)
+	}
+	db.Exec("DELETE FROM migrate_lock")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestLockTableConcurrentInstall(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "db") + "?_pragma=busy_timeout(5000)"
+	var runs int32
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithLockTable(), WithHistory(), WithLockStrategy(LockStrategy{Timeout: 10 * time.Second, PollInterval: 5 * time.Millisecond}))
+	s.Update(1, func(v int, tx *sql.Tx) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(20 * time.Millisecond)
+		_, er := tx.Exec("CREATE TABLE cc(x INT)")
+		return er
+	})
+
+	const installers = 4
+	errs := make(chan error, installers)
+	for i := 0; i < installers; i++ {
+		go func() {
+			db, er := sql.Open("sqlite", dsn)
+			if er != nil {
+				errs <- er
+				return
+			}
+			defer db.Close()
+			errs <- s.Install(db, 1)
+		}()
+	}
+	for i := 0; i < installers; i++ {
+		if er := <-errs; er != nil {
+			t.Fatal(er)
+		}
+	}
+	if runs != 1 {
+		t.Fatal(runs)
+	}
+
+	db, er := sql.Open("sqlite", dsn)
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer db.Close()
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM version").Scan(&n)
+	if n != 1 {
+		t.Fatal(n)
+	}
+	if v, er := Version(db); er != nil || v != 1 {
+		t.Fatal(v, er)
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE migrate_lock (lock_key VARCHAR(255) NOT NULL PRIMARY KEY, locked_at TIMESTAMP NOT NULL)")
+	db.Exec("INSERT INTO migrate_lock VALUES('version', CURRENT_TIMESTAMP)")
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithLockTable(), WithLockKey("billing"), WithLockStrategy(LockStrategy{Skip: true}))
+	s.UpdateSQL(1, "CREATE TABLE lk(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+
+	db.Exec("INSERT INTO migrate_lock VALUES('billing', CURRENT_TIMESTAMP)")
+	s.UpdateSQL(2, "CREATE TABLE lk2(x INT)")
+	if er := s.Install(db, 2); !errors.Is(er, ErrLockHeld) {
+		t.Fatal(er)
+	}
+
+	if AdvisoryLockID("billing") == AdvisoryLockID("version") || AdvisoryLockID("billing") != AdvisoryLockID("billing") {
+		t.Fatal("AdvisoryLockID")
+	}
+}
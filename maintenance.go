@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// MaintenanceDialect may be implemented by a Dialect to supply the statements
+// Schema.Maintenance runs. Dialects that don't implement it get no
+// maintenance.
+type MaintenanceDialect interface {
+	// Maintenance returns the statements that refresh the planner's
+	// statistics (and the like), each to be run outside any transaction.
+	Maintenance() []string
+}
+
+// Maintenance runs the dialect's statistics maintenance statements against db,
+// outside of any transaction: ANALYZE for the generic dialect and
+// sp_updatestats for SQL Server. It does nothing for MySQL, whose statistics
+// are per table, or for a Dialect that doesn't implement MaintenanceDialect.
+// To run it after every successful Install, register it as a hook:
+//
+//	schema.AfterCommit(schema.Maintenance)
+func (s *Schema) Maintenance(db *sql.DB) error {
+	s = s.forDB(db)
+	d, ok := s.getDialect().(MaintenanceDialect)
+	if !ok {
+		return nil
+	}
+
+	for _, statement := range d.Maintenance() {
+		if _, er := db.ExecContext(context.Background(), statement); er != nil {
+			return er
+		}
+	}
+
+	return nil
+}
+
+func (genericDialect) Maintenance() []string {
+	return []string{"ANALYZE"}
+}
+
+func (mysqlDialect) Maintenance() []string {
+	return nil
+}
+
+func (sqlServerDialect) Maintenance() []string {
+	return []string{"EXEC sp_updatestats"}
+}
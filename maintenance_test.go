@@ -0,0 +1,28 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE mt(x INT); CREATE INDEX mt_x ON mt(x); INSERT INTO mt VALUES (1), (2)")
+	s.AfterCommit(s.Maintenance)
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var n int
+	if er := db.QueryRow("SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'mt'").Scan(&n); er != nil || n == 0 {
+		t.Fatal(n, er)
+	}
+
+	db.Exec("DELETE FROM sqlite_stat1")
+	s.SetDialect(DialectMySQL)
+	if er := s.Maintenance(db); er != nil {
+		t.Fatal(er)
+	}
+	if er := db.QueryRow("SELECT COUNT(*) FROM sqlite_stat1").Scan(&n); er != nil || n != 0 {
+		t.Fatal(n, er)
+	}
+}
@@ -1,69 +1,160 @@
-// Package migrate provides a simple method for maintaining versioned SQL 
+// Package migrate provides a simple method for maintaining versioned SQL
 // database upgrades.
 //
 // Internally, migrate will maintain a version table that stores the current
 // schema version. The calling code, on startup, constructs a Schema object
 // that describes how to build the desired database schema (via Schema.Update).
 // These migrations are applied in the order given if the database version is
-// less than the parameter passed to Schema.Update. 
+// less than the parameter passed to Schema.Update.
 //
 // Migrations are all done by calling Schema.Install, and are all performed
 // within the same transaction (though this may mean nothing if your RDBMS does
-// not perform DDL updates transactionally, e.g. MySQL will screw you).
+// not perform DDL updates transactionally, e.g. MySQL will screw you). For such
+// databases each migration can instead be committed along with its own version
+// stamp so that a failed run can be resumed; see TxMode.
 package migrate
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 )
 
 type migration struct {
-	minVersion int
-	up         func(int, *sql.Tx) error
+	minVersion  int
+	name        string
+	description string
+	sql         string
+
+	// up is passed the Schema running the migration, which may be a copy of
+	// the one it was registered on (see forDB), so that SQL migrations use
+	// its dialect and executor.
+	up func(context.Context, *Schema, int, *sql.Tx) (int64, error)
+
+	// noTx is set for migrations registered with UpdateNoTx, whose up
+	// closure only reports that they can't run in a transaction.
+	noTx func(context.Context, *Schema, int, DB) error
+
+	// seed is set for migrations registered with Seed.
+	seed bool
+
+	// digest is the hash of a streamed file's SQL, which sql doesn't hold.
+	digest string
 }
 
 // Schema represents an ordered list of (minVersion, closure) pairs that are
 // applied to a database when Schema.Install is invoked.
 type Schema struct {
-	migrations []migration
+	migrations  []migration
+	afterCommit []func(*sql.DB) error
+	dialect     Dialect
+	clock       func() time.Time
+	downs       map[int]downMigration
+	adoptTable  string
+	adoptColumn string
+	prereqs     map[int][]int
+	txMode      TxMode
+	txWrapper   func(*sql.Tx) QueryExecutor
+	dirtyFlag   bool
+	createSQL   string
+	seedSQL     string
+	checkpoint  *migration
+	sources     []func() ([]Migration, error)
+	tags        map[int][]string
+	environment map[string]bool
+
+	detectVersion   func(*sql.DB) (int, bool, error)
+	idempotentDDL   bool
+	auditSink       AuditSink
+	history         bool
+	verbose         *log.Logger
+	batchSize       int
+	anyMaxVersion   bool
+	events          bool
+	versionColumns  map[string]interface{}
+	lockStrategy    LockStrategy
+	lockName        string
+	versionCap      bool
+	finalizers      []func(*sql.Tx) error
+	planHash        bool
+	checksums       bool
+	dryRun          bool
+	hooks           Hooks
+	outOfOrder      OutOfOrder
+	isFresh         func(*sql.DB) (bool, error)
+	database        string
+	lockRow         bool
+	historyName     string
+	sqlLog          *sqlLog
+	previews        map[int]string
+	appliedBy       func() string
+	recordAppliedBy bool
+	versionLog      bool
+	txOptions       *sql.TxOptions
+	retries         int
+	retryBackoff    time.Duration
+	exists          map[int]func(*sql.Tx) (bool, error)
+	origin          *Schema
 }
 
-func getDbVersion(db *sql.DB) (int, error) {
-	rows, er := db.Query("SELECT version FROM version")
-	if er != nil {
-		if _, er = db.Exec("CREATE TABLE version(version INT)"); er != nil {
-			return 0, er
-		}
+// MigrationError is returned when a migration's closure fails.
+type MigrationError struct {
+	// Version and Name identify the failing migration.
+	Version int
+	Name    string
 
-		if _, er = db.Exec("INSERT INTO version(version) VALUES(0)"); er != nil {
-			return 0, er
-		}
+	// Applied lists the versions of the migrations that completed earlier in
+	// the same run. With TxSingle these were rolled back along with the
+	// failing migration (unless a migration registered with UpdateNoTx came
+	// between them); with TxPerMigration they remain committed.
+	Applied []int
+
+	Err error
+}
 
-		return 0, nil
+func (e *MigrationError) Error() string {
+	msg := fmt.Sprintf("migrate: migration %d", e.Version)
+	if e.Name != "" {
+		msg += " (" + e.Name + ")"
 	}
 
-	if !rows.Next() {
-		rows.Close()
+	msg += " failed"
+	if len(e.Applied) > 0 {
+		msg += fmt.Sprintf(" after applying %v", e.Applied)
+	}
 
-		if _, er = db.Exec("INSERT INTO version(version) VALUES(0)"); er != nil {
-			return 0, er
-		}
+	return msg + ": " + e.Err.Error()
+}
 
-		return 0, nil
-	}
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
 
-	var version int
+// PostCommitError is returned by Schema.Install when the migration transaction
+// was committed but one of the AfterCommit hooks failed. The database is at the
+// new version; only the post-commit work is in question.
+type PostCommitError struct {
+	Err error
+}
 
-	if er = rows.Scan(&version); er != nil {
-		return 0, er
-	}
+func (e *PostCommitError) Error() string {
+	return "migrate: after commit: " + e.Err.Error()
+}
 
-	rows.Close()
-	return version, nil
+func (e *PostCommitError) Unwrap() error {
+	return e.Err
 }
 
-func setDbVersion(tx *sql.Tx, version int) error {
-	_, er := tx.Exec(`UPDATE version SET version = $1`, version)
-	return er
+// SetDialect sets the Dialect used for the version table bookkeeping. Passing
+// nil restores the default, which is detected from the database's driver.
+func (s *Schema) SetDialect(d Dialect) {
+	s.dialect = d
 }
 
 // Update appends an update closure to the receiving Schema. Updates are applied
@@ -72,44 +163,959 @@ func setDbVersion(tx *sql.Tx, version int) error {
 // migration is aborted. The closure is passed the database's current version and
 // a transaction in which to perform the migration.
 func (s *Schema) Update(minVersion int, f func(int, *sql.Tx) error) {
+	s.UpdateRows(minVersion, func(version int, tx *sql.Tx) (int64, error) {
+		return -1, f(version, tx)
+	})
+}
+
+// UpdateContext is like Update, but the closure is also passed the context
+// given to InstallContext (context.Background for Install), so that it can run
+// its statements with ExecContext and QueryContext and have a long-running
+// statement, such as a hung ALTER TABLE, aborted when the context is cancelled
+// or its deadline passes.
+func (s *Schema) UpdateContext(minVersion int, f func(context.Context, int, *sql.Tx) error) {
 	s.migrations = append(s.migrations, migration{
 		minVersion: minVersion,
-		up:         f,
+		up: func(ctx context.Context, _ *Schema, version int, tx *sql.Tx) (int64, error) {
+			return -1, f(ctx, version, tx)
+		},
+	})
+}
+
+// UpdateNamed is like Update, but also gives the migration a name and an
+// optional human-readable description of what it does. Both are shown by
+// Schema.Plan and WithVerbose and recorded in the history table.
+func (s *Schema) UpdateNamed(minVersion int, name, description string, f func(int, *sql.Tx) error) {
+	s.migrations = append(s.migrations, migration{
+		minVersion:  minVersion,
+		name:        name,
+		description: description,
+		up: func(_ context.Context, _ *Schema, version int, tx *sql.Tx) (int64, error) {
+			return -1, f(version, tx)
+		},
 	})
 }
 
+// UpdateSQL registers a migration that executes query within the migration
+// transaction. query may hold several statements, which are split and run one
+// at a time exactly as for files loaded by Schema.AddDir; an error names the
+// statement that failed.
+func (s *Schema) UpdateSQL(minVersion int, query string) {
+	s.migrations = append(s.migrations, migration{
+		minVersion: minVersion,
+		sql:        query,
+		up:         execStatements(query),
+	})
+}
+
+// MustUpdate is like Update, but panics if f is nil or minVersion is not
+// positive. Migrations are usually registered from init functions, where the
+// panic's stack trace points straight at the offending registration.
+func (s *Schema) MustUpdate(minVersion int, f func(int, *sql.Tx) error) {
+	if f == nil {
+		panic(fmt.Sprintf("migrate: nil closure for migration %d", minVersion))
+	}
+
+	if minVersion <= 0 {
+		panic(fmt.Sprintf("migrate: invalid minVersion %d", minVersion))
+	}
+
+	s.Update(minVersion, f)
+}
+
+// UpdateRows is like Update, but the closure also returns the number of rows it
+// affected, which is reported in the Result returned by Schema.InstallResult.
+// It is meant for data migrations (backfills and the like).
+func (s *Schema) UpdateRows(minVersion int, f func(int, *sql.Tx) (int64, error)) {
+	s.migrations = append(s.migrations, migration{
+		minVersion: minVersion,
+		up: func(_ context.Context, _ *Schema, version int, tx *sql.Tx) (int64, error) {
+			return f(version, tx)
+		},
+	})
+}
+
+// Finalize appends a callback that Install runs once, in the migration
+// transaction, after the last migration and before the version is stamped,
+// e.g. to refresh a materialized view that the migrations affect. It runs
+// however many migrations were applied, but not if the database was already
+// up to date. With TxPerMigration it runs in the transaction that stamps the
+// final version. Callbacks run in the order they were added, and a failure
+// aborts the whole run.
+func (s *Schema) Finalize(f func(*sql.Tx) error) {
+	s.finalizers = append(s.finalizers, f)
+}
+
+func (s *Schema) finalize(tx *sql.Tx) error {
+	for _, f := range s.finalizers {
+		if er := f(tx); er != nil {
+			return er
+		}
+	}
+
+	return nil
+}
+
+// AfterCommit appends a hook that is run after Schema.Install has successfully
+// committed the migration transaction. Hooks receive the raw database rather
+// than a transaction, so they may perform work that cannot be done inside one
+// (e.g. VACUUM). Hooks run in the order they were added; the first failure
+// stops the remaining hooks and is returned wrapped in a PostCommitError.
+func (s *Schema) AfterCommit(f func(*sql.DB) error) {
+	s.afterCommit = append(s.afterCommit, f)
+}
+
 // Install goes through each update closure passed to Schema.Update and applies
-// it if the database's version is less than the closure's minVersion.
-func (s *Schema) Install(db *sql.DB, maxVersion int) (retEr error) {
-	version, er := getDbVersion(db)
+// it if the database's version is less than the closure's minVersion. Once the
+// transaction has been committed, any AfterCommit hooks are run (which requires
+// db to be a *sql.DB).
+//
+// maxVersion caps the migrations that are applied: those with a minVersion
+// greater than it are left for a later Install. If it is the highest
+// registered minVersion the database is stamped with maxVersion; if it is
+// lower, with the highest minVersion that was actually applied. A maxVersion
+// above every registered minVersion is rejected with ErrMaxVersionExceedsKnown
+// unless AllowUnknownMaxVersion is set.
+func (s *Schema) Install(db DB, maxVersion int) error {
+	return s.InstallContext(context.Background(), db, maxVersion)
+}
+
+// InstallContext is like Install, but the migration transactions are bound to
+// ctx, so cancelling it (or its deadline passing) aborts the migration in
+// progress and rolls its transaction back. The MigrationError returned then
+// names the interrupted migration, and matches ctx's error with errors.Is.
+// Waiting for the migration lock is bound to ctx too: if ctx is done first,
+// its error is returned and nothing is migrated. ctx is passed on to closures
+// registered with UpdateContext and DownContext, and SQL migrations run their
+// statements under it.
+func (s *Schema) InstallContext(ctx context.Context, db DB, maxVersion int) error {
+	_, er := s.installResult(ctx, db, maxVersion)
+	return er
+}
+
+// InstallResult is like Install, but also returns a Result describing what was
+// done. The Result is nil if the migration transaction failed.
+func (s *Schema) InstallResult(db DB, maxVersion int) (*Result, error) {
+	return s.installResult(context.Background(), db, maxVersion)
+}
+
+func (s *Schema) installResult(ctx context.Context, db DB, maxVersion int) (*Result, error) {
+	s = s.forDB(db)
+	res := &Result{}
+
+	migrations, er := s.collect()
+	if er != nil {
+		return nil, er
+	}
+
+	if er := s.checkMaxVersion(migrations, maxVersion); er != nil {
+		return nil, er
+	}
+
+	er = s.session(ctx, db, func(conn DB) error {
+		return s.migrate(ctx, conn, migrations, maxVersion, res)
+	})
+	if auditEr := s.audit(ctx, res, er); er == nil {
+		er = auditEr
+	}
+	if er != nil {
+		return nil, er
+	}
+
+	if s.dryRun {
+		return res, nil
+	}
+
+	return res, s.runAfterCommit(db)
+}
+
+// InstallVerbose is like Install, but also returns the database's version as
+// read at the start and the version it was stamped with, e.g. for logging
+// "schema: from -> to" at startup. Both are 0 if the migrations failed.
+func (s *Schema) InstallVerbose(db DB, maxVersion int) (from, to int, er error) {
+	res, er := s.InstallResult(db, maxVersion)
+	if res == nil {
+		return 0, 0, er
+	}
+
+	return res.From, res.To, er
+}
+
+// ErrUnknownTarget is returned by Schema.InstallTo when the target version
+// isn't the minVersion of any registered migration.
+var ErrUnknownTarget = errors.New("migrate: no migration is registered for the target version")
+
+// InstallTo upgrades db partway, e.g. to stage a risky change on its own: it is
+// like Install(db, targetVersion), but targetVersion must be the minVersion of
+// a registered migration (or of the Checkpoint), and ErrUnknownTarget is
+// returned otherwise, so that a typo can't stamp a version no migration
+// reaches. The database is stamped with targetVersion once the migrations up
+// to it have been applied. A database already at or past targetVersion is left
+// alone; use Rollback to go back.
+func (s *Schema) InstallTo(db DB, targetVersion int) error {
+	migrations, er := s.collect()
 	if er != nil {
 		return er
 	}
 
-	tx, er := db.Begin()
+	known := s.checkpoint != nil && s.checkpoint.minVersion == targetVersion
+	for _, m := range migrations {
+		if m.minVersion == targetVersion {
+			known = true
+		}
+	}
+
+	if !known {
+		return fmt.Errorf("%w: %d", ErrUnknownTarget, targetVersion)
+	}
+
+	return s.Install(db, targetVersion)
+}
+
+// InstallStepwise is like Install, but always commits each migration (or group
+// of migrations sharing a minVersion) in its own transaction along with a
+// stamp of its minVersion, as with TxPerMigration, whatever TxMode is
+// configured. A failed run leaves the database at the last migration that
+// succeeded, and the next Install or InstallStepwise resumes from there.
+func (s *Schema) InstallStepwise(db DB, maxVersion int) (*Result, error) {
+	stepwise := s.clone()
+	stepwise.txMode = TxPerMigration
+	return stepwise.InstallResult(db, maxVersion)
+}
+
+func (s *Schema) runAfterCommit(db DB) error {
+	if len(s.afterCommit) == 0 {
+		return nil
+	}
+
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return &PostCommitError{Err: errors.New("migrate: AfterCommit hooks need a *sql.DB")}
+	}
+
+	for _, f := range s.afterCommit {
+		if er := f(sqlDB); er != nil {
+			return &PostCommitError{Err: er}
+		}
+	}
+
+	return nil
+}
+
+// InstallFrom is an escape hatch for recovering a database whose version table
+// was lost or damaged. Rather than reading the current version, it trusts the
+// caller's assumeCurrent: the version table is (re)created if necessary and
+// stamped with assumeCurrent, and then every migration with a minVersion
+// greater than assumeCurrent is applied, exactly as Install would. As
+// assumeCurrent stands in for them, AdoptFrom, DetectExistingVersion and
+// WithFreshCheck are ignored.
+func (s *Schema) InstallFrom(db *sql.DB, assumeCurrent, maxVersion int) error {
+	recovery := s.forDB(db).clone()
+	recovery.adoptTable, recovery.detectVersion, recovery.isFresh = "", nil, nil
+	return recovery.installFrom(db, assumeCurrent, maxVersion)
+}
+
+func (s *Schema) installFrom(db *sql.DB, assumeCurrent, maxVersion int) error {
+	ctx := context.Background()
+	res := &Result{}
+
+	migrations, er := s.collect()
 	if er != nil {
 		return er
 	}
-	defer func() {
-		if retEr != nil {
-			tx.Rollback()
 
-		} else {
-			retEr = tx.Commit()
+	if er := s.checkMaxVersion(migrations, maxVersion); er != nil {
+		return er
+	}
+
+	er = s.session(ctx, db, func(conn DB) error {
+		er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			log.Printf("migrate: WARNING: overriding database version %d with assumed version %d", version, assumeCurrent)
+			return s.setDbVersion(ctx, tx, version, assumeCurrent)
+		})
+		if er != nil {
+			return er
 		}
-	}()
 
-	for _, migration := range s.migrations {
-		if migration.minVersion > version {
-			if er := migration.up(version, tx); er != nil {
+		return s.migrate(ctx, conn, migrations, maxVersion, res)
+	})
+	if auditEr := s.audit(ctx, res, er); er == nil {
+		er = auditEr
+	}
+	if er != nil {
+		return er
+	}
+
+	return s.runAfterCommit(db)
+}
+
+// ApplyVersions is a recovery tool that re-runs the up closures of the
+// migrations registered with each of versions, in ascending order, within one
+// transaction, whatever the database's version. The version stamp, history
+// and prerequisites are all left alone, as this bypasses the normal version
+// gating entirely; it is only meant for re-applying idempotent migrations
+// after a data problem. A version with no registered migration is an error,
+// reported before anything runs.
+func (s *Schema) ApplyVersions(db *sql.DB, versions []int) error {
+	s = s.forDB(db)
+	ctx := context.Background()
+
+	migrations, er := s.collect()
+	if er != nil {
+		return er
+	}
+
+	sorted := append([]int(nil), versions...)
+	sort.Ints(sorted)
+
+	var selected []migration
+	for _, v := range sorted {
+		found := false
+		for _, m := range migrations {
+			if m.minVersion == v {
+				selected = append(selected, m)
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("migrate: no migration registered for version %d", v)
+		}
+	}
+
+	return s.session(ctx, db, func(conn DB) error {
+		return s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			log.Printf("migrate: WARNING: re-applying versions %v to a database at version %d, bypassing version checks", sorted, version)
+
+			for _, m := range selected {
+				s.sqlLog.setVersion(m.minVersion)
+				_, er := m.up(ctx, s, version, tx)
+				s.sqlLog.setVersion(0)
+				if er != nil {
+					return &MigrationError{Version: m.minVersion, Name: m.name, Err: er}
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// migrate applies the pending migrations using the configured TxMode.
+func (s *Schema) migrate(ctx context.Context, conn DB, migrations []migration, maxVersion int, res *Result) error {
+	res.target = maxVersion
+
+	if er := s.preflight(ctx, conn, migrations); er != nil {
+		return er
+	}
+
+	if s.dryRun {
+		return s.applyDryRun(ctx, conn, migrations, maxVersion, res)
+	}
+
+	if s.perMigration() {
+		return s.applyEach(ctx, conn, migrations, maxVersion, 1, res)
+	}
+
+	if s.batchSize > 0 {
+		return s.applyEach(ctx, conn, migrations, maxVersion, s.batchSize, res)
+	}
+
+	// A non-transactional migration splits the transaction in two.
+	if noTx, er := s.noTxPending(ctx, conn, migrations, maxVersion); er != nil {
+		return er
+
+	} else if noTx {
+		return s.applyEach(ctx, conn, migrations, maxVersion, 0, res)
+	}
+
+	er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+		return s.apply(ctx, tx, migrations, version, maxVersion, res)
+	})
+	if er != nil {
+		return er
+	}
+
+	res.committed = len(res.Applied)
+	return nil
+}
+
+// preflight makes the checks that must pass, once the migration lock is held,
+// before any of migrations is applied.
+func (s *Schema) preflight(ctx context.Context, conn DB, migrations []migration) error {
+	if er := s.checkClean(ctx, conn); er != nil {
+		return er
+	}
+
+	if er := s.checkPlanHash(ctx, conn, migrations); er != nil {
+		return er
+	}
+
+	if er := s.checkChecksums(ctx, conn, migrations); er != nil {
+		return er
+	}
+
+	if er := s.checkOutOfOrder(ctx, conn, migrations); er != nil {
+		return er
+	}
+
+	if er := s.validateCheckpoint(migrations); er != nil {
+		return er
+	}
+
+	if er := validateNoTx(migrations); er != nil {
+		return er
+	}
+
+	return validateSeeds(migrations)
+}
+
+// apply runs every migration whose minVersion is greater than version and no
+// greater than maxVersion, then stamps the version given by stampVersion,
+// recording what it did in res.
+func (s *Schema) apply(ctx context.Context, tx *sql.Tx, migrations []migration, version, maxVersion int, res *Result) error {
+	res.Applied, res.Skipped = nil, nil
+	res.From = version
+	from := version
+	ran := make(map[int]bool)
+
+	if planned := s.plannedVersion(migrations, version, maxVersion); planned > version {
+		if er := s.checkCap(ctx, s.executor(tx), planned); er != nil {
+			return er
+		}
+	}
+
+	if er := s.applyMissed(ctx, tx, migrations, version, ran, res); er != nil {
+		return er
+	}
+
+	if version == 0 && s.checkpoint != nil && s.checkpoint.minVersion <= maxVersion {
+		if er := s.runMigration(ctx, tx, version, *s.checkpoint, ran, res); er != nil {
+			return er
+		}
+
+		version = s.checkpoint.minVersion
+	}
+
+	for _, migration := range migrations {
+		if s.pending(migration, version, maxVersion) {
+			if er := s.runMigration(ctx, tx, version, migration, ran, res); er != nil {
+				return er
+			}
+		}
+	}
+
+	// Migrations applied out of order mustn't lower the stamp.
+	to := s.stampVersion(migrations, from, maxVersion, res)
+	if to < from {
+		to = from
+	}
+
+	if len(res.Applied) == 0 && len(res.Skipped) == 0 && from >= to {
+		res.To = from
+		res.UpToDate = true
+		return nil
+	}
+
+	if er := s.finalize(tx); er != nil {
+		return er
+	}
+
+	if er := s.setInstalledVersion(ctx, tx, migrations, from, to); er != nil {
+		return er
+	}
+
+	res.To = to
+	return nil
+}
+
+// plannedVersion returns the version a run starting at version is expected to
+// stamp, assuming every pending migration runs.
+func (s *Schema) plannedVersion(migrations []migration, version, maxVersion int) int {
+	if maxVersion >= s.latestVersion(migrations) {
+		return maxVersion
+	}
+
+	planned := version
+	if version == 0 && s.checkpoint != nil && s.checkpoint.minVersion <= maxVersion {
+		planned = s.checkpoint.minVersion
+	}
+
+	for _, m := range migrations {
+		if m.minVersion > planned && m.minVersion <= maxVersion {
+			planned = m.minVersion
+		}
+	}
+
+	return planned
+}
+
+// stampVersion returns the version to stamp once a run that started at from has
+// applied the migrations recorded in res: maxVersion, unless it is below the
+// highest registered minVersion, in which case the highest minVersion that ran
+// (or from, if none did).
+func (s *Schema) stampVersion(migrations []migration, from, maxVersion int, res *Result) int {
+	if maxVersion >= s.latestVersion(migrations) {
+		return maxVersion
+	}
+
+	to := from
+	for _, a := range res.Applied {
+		if a.Version > to {
+			to = a.Version
+		}
+	}
+
+	for _, versions := range [][]int{res.Skipped, res.Resumed} {
+		for _, v := range versions {
+			if v > to {
+				to = v
+			}
+		}
+	}
+
+	return to
+}
+
+// applyEach is the TxPerMigration (and WithBatchSize) counterpart of apply:
+// pending migrations are committed in batches of batchSize groups of
+// migrations sharing a minVersion (or as many as possible, if batchSize is
+// 0), each batch stamping the highest minVersion it ran, so a failed run can
+// be resumed from the last committed batch. A non-transactional migration
+// ends the batch before it and is run on its own by applyNoTx. Groups above
+// maxVersion are left alone. Which migrations are pending is decided up front
+// from the version at the start, exactly as in apply.
+func (s *Schema) applyEach(ctx context.Context, conn DB, migrations []migration, maxVersion, batchSize int, res *Result) error {
+	start, er := s.readVersion(ctx, conn)
+	if er != nil {
+		return er
+	}
+
+	res.From = start
+	ran := make(map[int]bool)
+	pending := migrations
+
+	if s.outOfOrder == OutOfOrderApply {
+		er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			res.Applied, res.Skipped = res.Applied[:0], res.Skipped[:0]
+			if er := s.applyMissed(ctx, tx, migrations, version, ran, res); er != nil {
+				return er
+			}
+
+			if len(res.Applied) == 0 {
+				return nil
+			}
+
+			return s.setInstalledVersion(ctx, tx, migrations, version, version)
+		})
+		if er != nil {
+			return er
+		}
+
+		res.committed = len(res.Applied)
+	}
+
+	if start == 0 && s.checkpoint != nil && s.checkpoint.minVersion <= maxVersion {
+		er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			res.Applied, res.Skipped = res.Applied[:0], res.Skipped[:0]
+			if er := s.runMigration(ctx, tx, version, *s.checkpoint, ran, res); er != nil {
+				return er
+			}
+
+			return s.setInstalledVersion(ctx, tx, migrations, version, s.checkpoint.minVersion)
+		})
+		if er != nil {
+			return er
+		}
+
+		res.committed = len(res.Applied)
+
+		start = s.checkpoint.minVersion
+	}
+
+	var groups [][]migration
+	for len(pending) > 0 {
+		n := 1
+		for n < len(pending) && pending[n].minVersion == pending[0].minVersion {
+			n++
+		}
+
+		group := pending[:n]
+		pending = pending[n:]
+
+		if !s.pending(group[0], start, maxVersion) {
+			for _, migration := range group[1:] {
+				s.pending(migration, start, maxVersion)
+			}
+
+			continue
+		}
+
+		groups = append(groups, group)
+	}
+
+	for len(groups) > 0 {
+		if groups[0][0].noTx != nil {
+			if er := s.applyNoTx(ctx, conn, migrations, groups[0][0], ran, res); er != nil {
 				return er
 			}
+
+			groups = groups[1:]
+			res.committed = len(res.Applied)
+			continue
+		}
+
+		n := batchSize
+		if n <= 0 || n > len(groups) {
+			n = len(groups)
+		}
+
+		for i := 1; i < n; i++ {
+			if groups[i][0].noTx != nil {
+				n = i
+				break
+			}
+		}
+
+		batch := groups[:n]
+		groups = groups[n:]
+		applied, skipped := len(res.Applied), len(res.Skipped)
+
+		er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			res.Applied, res.Skipped = res.Applied[:applied], res.Skipped[:skipped]
+			stamp := version
+			for _, group := range batch {
+				for _, migration := range group {
+					if er := s.runMigration(ctx, tx, version, migration, ran, res); er != nil {
+						return er
+					}
+				}
+
+				if group[0].minVersion > stamp {
+					stamp = group[0].minVersion
+				}
+			}
+
+			if stamp > version {
+				if er := s.checkCap(ctx, s.executor(tx), stamp); er != nil {
+					return er
+				}
+
+				return s.setInstalledVersion(ctx, tx, migrations, version, stamp)
+			}
+
+			return nil
+		})
+		if er != nil {
+			return er
 		}
+
+		res.committed = len(res.Applied)
 	}
 
-	if er := setDbVersion(tx, maxVersion); er != nil {
+	to := s.stampVersion(migrations, res.From, maxVersion, res)
+	if to < res.From {
+		to = res.From
+	}
+
+	if len(res.Applied) == 0 && len(res.Skipped) == 0 && res.From >= to {
+		res.To = res.From
+		res.UpToDate = true
+		return nil
+	}
+
+	er = s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+		if to > version {
+			if er := s.checkCap(ctx, s.executor(tx), to); er != nil {
+				return er
+			}
+		}
+
+		if er := s.finalize(tx); er != nil {
+			return er
+		}
+
+		return s.setInstalledVersion(ctx, tx, migrations, version, to)
+	})
+	if er != nil {
 		return er
 	}
 
+	res.To = to
 	return nil
 }
+
+// migrationError returns the MigrationError for m's closure failing with er
+// after the migrations in res were applied.
+func migrationError(ctx context.Context, m migration, res *Result, er error) error {
+	applied := make([]int, len(res.Applied))
+	for i, a := range res.Applied {
+		applied[i] = a.Version
+	}
+
+	// A cancelled context shows up in the closure as whatever error the
+	// aborted transaction gave it (often sql.ErrTxDone), so report the
+	// cancellation itself.
+	if ctxEr := ctx.Err(); ctxEr != nil && !errors.Is(er, ctxEr) {
+		er = fmt.Errorf("%w: %v", ctxEr, er)
+	}
+
+	return &MigrationError{
+		Version: m.minVersion,
+		Name:    m.name,
+		Applied: applied,
+		Err:     er,
+	}
+}
+
+// runMigration runs a single migration's up closure in tx, after checking its
+// prerequisites against version and the migrations that already ran.
+func (s *Schema) runMigration(ctx context.Context, tx *sql.Tx, version int, migration migration, ran map[int]bool, res *Result) error {
+	if er := s.checkPrerequisites(migration.minVersion, version, ran); er != nil {
+		return er
+	}
+
+	if !s.tagsActive(migration.minVersion) {
+		if er := s.recordSkipped(ctx, tx, migration); er != nil {
+			return er
+		}
+
+		s.logMigration(migration, "skipped: none of its tags is active")
+		res.Skipped = append(res.Skipped, migration.minVersion)
+		ran[migration.minVersion] = true
+		return nil
+	}
+
+	if exists := s.exists[migration.minVersion]; exists != nil {
+		present, er := exists(tx)
+		if er != nil {
+			return er
+		}
+
+		if present {
+			s.logMigration(migration, "skipped: already applied")
+			res.Skipped = append(res.Skipped, migration.minVersion)
+			ran[migration.minVersion] = true
+			return nil
+		}
+	}
+
+	if er := s.setDirty(ctx, tx, true); er != nil {
+		return er
+	}
+
+	start := s.now()
+	after := s.beforeMigration(ctx, migration, version, res)
+	s.sqlLog.setVersion(migration.minVersion)
+	rows, er := migration.up(ctx, s, version, tx)
+	s.sqlLog.setVersion(0)
+	after(s.now().Sub(start), er)
+	if er != nil {
+		return migrationError(ctx, migration, res, er)
+	}
+
+	applied := AppliedMigration{
+		Version:      migration.minVersion,
+		Name:         migration.name,
+		Description:  migration.description,
+		RowsAffected: rows,
+		Duration:     s.now().Sub(start),
+		Checksum:     migration.hash(),
+	}
+
+	if er := s.recordHistory(ctx, tx, applied); er != nil {
+		return er
+	}
+
+	if er := s.recordEvent(ctx, tx, migration.minVersion, "up", migration.name); er != nil {
+		return er
+	}
+
+	res.Applied = append(res.Applied, applied)
+	ran[migration.minVersion] = true
+	s.logMigration(migration, "applied in %v", applied.Duration)
+	return nil
+}
+
+// ErrInstallInProgress is returned when a Schema is asked to migrate a database
+// while it is already migrating that same database from another goroutine.
+var ErrInstallInProgress = errors.New("migrate: install already in progress")
+
+// installing holds an installKey for every (Schema, DB) pair currently being
+// migrated within this process.
+var installing sync.Map
+
+type installKey struct {
+	schema *Schema
+	db     DB
+}
+
+// guard marks s (or, for a clone, the Schema it was cloned from) as migrating
+// db, returning a function that clears the mark, or ErrInstallInProgress if it
+// is already marked. A db whose type can't be used as a map key (a struct
+// holding a slice, say) isn't guarded.
+func (s *Schema) guard(db DB) (func(), error) {
+	if t := reflect.TypeOf(db); t != nil && !t.Comparable() {
+		return func() {}, nil
+	}
+
+	schema := s
+	if s.origin != nil {
+		schema = s.origin
+	}
+
+	key := installKey{schema, db}
+	if _, busy := installing.LoadOrStore(key, true); busy {
+		return nil, ErrInstallInProgress
+	}
+
+	return func() { installing.Delete(key) }, nil
+}
+
+// session takes a dedicated connection and holds the dialect's migration lock
+// on it while it bootstraps the version table and runs f. If db can't hand out
+// dedicated connections (it has no Conn method) f is passed db itself, and no
+// lock is taken.
+func (s *Schema) session(ctx context.Context, db DB, f func(DB) error) (retEr error) {
+	release, er := s.guard(db)
+	if er != nil {
+		return er
+	}
+	defer release()
+
+	// Probes such as DetectExistingVersion need db itself, which the
+	// dedicated connection may exhaust, so the version a missing version
+	// table would be seeded with is worked out first (unless the table has
+	// to be found via WithDatabase). The table is only created under the
+	// lock, so that instances booting together can't both create it.
+	var initial int
+	if s.database == "" {
+		if initial, er = s.newVersion(ctx, db); er != nil {
+			return er
+		}
+	}
+
+	var conn DB = db
+	if pool, ok := db.(connector); ok {
+		c, er := pool.Conn(ctx)
+		if er != nil {
+			return er
+		}
+		defer c.Close()
+
+		if er := s.useDatabase(ctx, c); er != nil {
+			return er
+		}
+
+		if er := s.lock(ctx, c); er != nil {
+			return er
+		}
+		defer func() {
+			if er := s.unlock(c); er != nil && retEr == nil {
+				retEr = er
+			}
+		}()
+
+		conn = c
+
+	} else if er := s.useDatabase(ctx, db); er != nil {
+		return er
+	}
+
+	if s.database != "" {
+		if initial, er = s.newVersion(ctx, conn); er != nil {
+			return er
+		}
+	}
+
+	if _, er := s.getDbVersion(ctx, conn, initial); er != nil {
+		return er
+	}
+
+	if s.dirtyFlag {
+		if s.versionLog {
+			return errors.New("migrate: WithDirtyFlag can't be combined with WithVersionLog")
+		}
+
+		if er := s.ensureColumn(ctx, conn, versionTable, "dirty", "INT NOT NULL DEFAULT 0"); er != nil {
+			return er
+		}
+	}
+
+	if s.versionCap {
+		if s.versionLog {
+			return errors.New("migrate: WithVersionCap can't be combined with WithVersionLog")
+		}
+
+		if er := s.ensureColumn(ctx, conn, versionTable, "max_allowed", "INT NULL"); er != nil {
+			return er
+		}
+	}
+
+	if s.planHash {
+		if s.versionLog {
+			return errors.New("migrate: WithPlanHash can't be combined with WithVersionLog")
+		}
+
+		if er := s.ensureColumn(ctx, conn, versionTable, "plan_hash", "VARCHAR(64) NULL"); er != nil {
+			return er
+		}
+	}
+
+	if er := s.ensureHistoryTable(ctx, conn); er != nil {
+		return er
+	}
+
+	if er := s.ensureEventsTable(ctx, conn); er != nil {
+		return er
+	}
+
+	return f(conn)
+}
+
+// transact opens a transaction on conn and passes it to f along with the
+// database's current version. The transaction is committed if f returns nil
+// and rolled back otherwise. A transaction that fails with a serialization
+// failure or deadlock is retried as configured by WithRetry, so f must be safe
+// to call again.
+func (s *Schema) transact(ctx context.Context, conn DB, f func(*sql.Tx, int) error) error {
+	for attempt := 0; ; attempt++ {
+		er := s.transactOnce(ctx, conn, f)
+		if er == nil || attempt >= s.retries || !IsSerializationFailure(er) && !IsDeadlock(er) {
+			return er
+		}
+
+		select {
+		case <-ctx.Done():
+			return er
+
+		case <-time.After(s.retryBackoff << uint(attempt)):
+		}
+	}
+}
+
+func (s *Schema) transactOnce(ctx context.Context, conn DB, f func(*sql.Tx, int) error) (retEr error) {
+	tx, er := conn.BeginTx(ctx, s.txOptions)
+	if er != nil {
+		return er
+	}
+	defer func() {
+		var failed *MigrationError
+		if retEr != nil && autoCommits(s.getDialect()) && errors.As(retEr, &failed) {
+			tx.Commit()
+
+		} else if retEr != nil {
+			tx.Rollback()
+
+		} else {
+			retEr = tx.Commit()
+		}
+	}()
+
+	version, er := s.readVersion(ctx, s.executor(tx))
+	if er != nil {
+		return er
+	}
+
+	return f(tx, version)
+}
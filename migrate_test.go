@@ -0,0 +1,452 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	db, er := sql.Open("sqlite", ":memory:")
+	if er != nil {
+		t.Fatal(er)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestInstall(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Update(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE a(x INT)"); return er })
+	s.Update(2, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE b(x INT)"); return er })
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	var v int
+	db.QueryRow("SELECT version FROM version").Scan(&v)
+	if v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestPerMigration(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithTxMode(TxPerMigration))
+	s.Update(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE a(x INT)"); return er })
+	s.Update(2, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE b(x INT)"); return er })
+	s.Update(3, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE nope nope"); return er })
+	if er := s.Install(db, 3); er == nil {
+		t.Fatal("expected error")
+	}
+	v, er := Version(db)
+	if er != nil || v != 2 {
+		t.Fatal(v, er)
+	}
+	if _, er := Version(openDB(t)); er == nil {
+		t.Fatal("want ErrNoVersionTable")
+	}
+}
+
+func TestInstallResultCap(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	for i := 1; i <= 4; i++ {
+		s.Update(i, func(v int, tx *sql.Tx) error { return nil })
+	}
+	res, er := s.InstallResult(db, 2)
+	if er != nil || res.To != 2 || len(res.Applied) != 2 {
+		t.Fatal(er, res)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithBatchSize(2))
+	for i := 1; i <= 5; i++ {
+		i := i
+		s.Update(i, func(v int, tx *sql.Tx) error {
+			if i == 4 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+	}
+	if er := s.Install(db, 5); er == nil {
+		t.Fatal("expected failure")
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Update(1, func(v int, tx *sql.Tx) error {
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		_, er := tx.Exec("CREATE TABLE zz(x INT)")
+		return er
+	})
+	er := s.InstallContext(ctx, db, 1)
+	var me *MigrationError
+	if !errors.Is(er, context.Canceled) || !errors.As(er, &me) || me.Version != 1 {
+		t.Fatal(er)
+	}
+}
+
+func TestFreshCheck(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE legacy(x INT)")
+	var s Schema
+	s.Configure(WithFreshCheck(func(db *sql.DB) (bool, error) {
+		var n int
+		er := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&n)
+		return n == 0, er
+	}))
+	s.Update(1, func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 1); !errors.Is(er, ErrNotFresh) {
+		t.Fatal(er)
+	}
+	if er := s.Install(openDB(t), 1); er != nil {
+		t.Fatal(er)
+	}
+
+	// Recovery trusts the caller instead.
+	s.UpdateSQL(2, "INSERT INTO legacy VALUES(2)")
+	if er := s.InstallFrom(db, 1, 2); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestAutoCommit(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(SimulateAutoCommitDDL(nil))
+	fail := true
+	s.Configure(WithIdempotentDDL())
+	s.UpdateSQL(1, "CREATE TABLE ac(x INT)")
+	s.Update(2, func(v int, tx *sql.Tx) error {
+		if _, er := tx.Exec("CREATE TABLE ac2(x INT)"); er != nil {
+			return er
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if er := s.Install(db, 2); er == nil {
+		t.Fatal("expected failure")
+	}
+	if v, _ := Version(db); v != 1 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM ac2"); er != nil {
+		t.Fatal("ac2 was rolled back:", er)
+	}
+	fail = false
+	if er := s.Install(db, 2); er == nil {
+		t.Fatal("expected non-idempotent closure to fail")
+	}
+}
+
+func TestUpdateContext(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	type key struct{}
+	var got interface{}
+	s.UpdateContext(1, func(ctx context.Context, version int, tx *sql.Tx) error {
+		got = ctx.Value(key{})
+		_, er := tx.ExecContext(ctx, "CREATE TABLE uc(x INT)")
+		return er
+	})
+	s.DownContext(1, func(ctx context.Context, version int, tx *sql.Tx) error {
+		_, er := tx.ExecContext(ctx, "DROP TABLE uc")
+		return er
+	})
+	if er := s.InstallContext(context.WithValue(context.Background(), key{}, "v"), db, 1); er != nil || got != "v" {
+		t.Fatal(er, got)
+	}
+	if er := s.Rollback(db, 0); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.UpdateSQL(1, "CREATE TABLE dr(x INT); INSERT INTO dr VALUES(1)")
+	plan, er := s.Plan(db, 1)
+	if er != nil || len(plan.Migrations) != 1 || len(plan.Migrations[0].Statements) != 2 {
+		t.Fatal(er, plan)
+	}
+	s.Configure(WithDryRun())
+	res, er := s.InstallResult(db, 1)
+	if er != nil || len(res.Applied) != 1 || res.To != 1 {
+		t.Fatal(er, res)
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM dr"); er == nil {
+		t.Fatal("table kept")
+	}
+}
+
+func TestInstallTo(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.UpdateSQL(1, "CREATE TABLE it1(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE it3(x INT)")
+	s.UpdateSQL(5, "CREATE TABLE it5(x INT)")
+	if er := s.InstallTo(db, 2); !errors.Is(er, ErrUnknownTarget) {
+		t.Fatal(er)
+	}
+	if er := s.InstallTo(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 3 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM it5"); er == nil {
+		t.Fatal("it5 exists")
+	}
+	if er := s.Install(db, 4); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 3 {
+		t.Fatal(v)
+	}
+}
+
+// taggedDB is a DB that can't be used as a map key.
+type taggedDB struct {
+	*sql.DB
+	tags []string
+}
+
+func TestInstallNonComparableDB(t *testing.T) {
+	db := taggedDB{openDB(t), []string{"primary"}}
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE nc(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if v, er := Version(db); er != nil || v != 1 {
+		t.Fatal(v, er)
+	}
+}
+
+func TestInstallToCheckpoint(t *testing.T) {
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Checkpoint(5, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE itc(x INT)"); return er })
+	db := openDB(t)
+	if er := s.InstallTo(db, 5); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 5 {
+		t.Fatal(v)
+	}
+
+	s.UpdateSQL(7, "CREATE TABLE itc7(x INT)")
+	db = openDB(t)
+	if er := s.InstallTo(db, 5); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 5 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM itc7"); er == nil {
+		t.Fatal("itc7 exists")
+	}
+	if er := s.InstallTo(db, 6); !errors.Is(er, ErrUnknownTarget) {
+		t.Fatal(er)
+	}
+}
+
+func TestAfterCommit(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE ac1(x INT)")
+	var order []int
+	s.AfterCommit(func(db *sql.DB) error {
+		if v, er := Version(db); er != nil || v != 1 {
+			t.Fatal(v, er)
+		}
+		order = append(order, 1)
+		return nil
+	})
+	boom := errors.New("boom")
+	s.AfterCommit(func(*sql.DB) error { order = append(order, 2); return boom })
+	s.AfterCommit(func(*sql.DB) error { order = append(order, 3); return nil })
+
+	er := s.Install(db, 1)
+	var pe *PostCommitError
+	if !errors.As(er, &pe) || !errors.Is(er, boom) {
+		t.Fatal(er)
+	}
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Fatal(order)
+	}
+	if v, _ := Version(db); v != 1 {
+		t.Fatal(v)
+	}
+}
+
+func TestUpdateRows(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.UpdateSQL(1, "CREATE TABLE ur(x INT); INSERT INTO ur VALUES (1), (2), (3)")
+	s.UpdateRows(2, func(v int, tx *sql.Tx) (int64, error) {
+		r, er := tx.Exec("UPDATE ur SET x = x * 10 WHERE x > 1")
+		if er != nil {
+			return 0, er
+		}
+		return r.RowsAffected()
+	})
+	res, er := s.InstallResult(db, 2)
+	if er != nil || len(res.Applied) != 2 || res.Applied[0].RowsAffected != -1 || res.Applied[1].RowsAffected != 2 {
+		t.Fatal(res, er)
+	}
+
+	var rows sql.NullInt64
+	if er := db.QueryRow("SELECT rows_affected FROM migration_history WHERE version = 2").Scan(&rows); er != nil || rows.Int64 != 2 {
+		t.Fatal(rows, er)
+	}
+}
+
+func TestInstallVerbose(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE iv1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE iv2(x INT)")
+	if from, to, er := s.InstallVerbose(db, 1); er != nil || from != 0 || to != 1 {
+		t.Fatal(from, to, er)
+	}
+	if from, to, er := s.InstallVerbose(db, 2); er != nil || from != 1 || to != 2 {
+		t.Fatal(from, to, er)
+	}
+
+	s.UpdateSQL(3, "CREATE TABLE nope nope")
+	if from, to, er := s.InstallVerbose(db, 3); er == nil || from != 0 || to != 0 {
+		t.Fatal(from, to, er)
+	}
+}
+
+func TestMustUpdate(t *testing.T) {
+	panics := func(f func()) (p bool) {
+		defer func() { p = recover() != nil }()
+		f()
+		return false
+	}
+
+	var s Schema
+	if !panics(func() { s.MustUpdate(1, nil) }) {
+		t.Fatal("nil closure accepted")
+	}
+	if !panics(func() { s.MustUpdate(0, func(int, *sql.Tx) error { return nil }) }) {
+		t.Fatal("minVersion 0 accepted")
+	}
+	if len(s.migrations) != 0 {
+		t.Fatal(len(s.migrations))
+	}
+
+	db := openDB(t)
+	s.MustUpdate(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE mu1(x INT)"); return er })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM mu1"); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE fz1(x INT); CREATE TABLE fz_log(n INT)")
+	s.UpdateSQL(2, "CREATE TABLE fz2(x INT)")
+	var order []string
+	s.Finalize(func(tx *sql.Tx) error {
+		var n int
+		if er := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'fz2'").Scan(&n); er != nil || n != 1 {
+			t.Fatal(n, er)
+		}
+		order = append(order, "first")
+		_, er := tx.Exec("INSERT INTO fz_log VALUES (1)")
+		return er
+	})
+	s.Finalize(func(*sql.Tx) error { order = append(order, "second"); return nil })
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatal(order)
+	}
+
+	if er := s.Install(db, 2); er != nil || len(order) != 2 {
+		t.Fatal(order, er)
+	}
+
+	boom := errors.New("boom")
+	s.UpdateSQL(3, "CREATE TABLE fz3(x INT)")
+	s.Finalize(func(*sql.Tx) error { return boom })
+	if er := s.Install(db, 3); !errors.Is(er, boom) {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM fz3"); er == nil {
+		t.Fatal("fz3 exists")
+	}
+}
+
+func TestApplyVersions(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE av(x INT)")
+	var order []int
+	for _, n := range []int{2, 3} {
+		n := n
+		s.Update(n, func(int, *sql.Tx) error { order = append(order, n); return nil })
+	}
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+
+	order = nil
+	if er := s.ApplyVersions(db, []int{3, 2}); er != nil {
+		t.Fatal(er)
+	}
+	if len(order) != 2 || order[0] != 2 || order[1] != 3 {
+		t.Fatal(order)
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+
+	order = nil
+	if er := s.ApplyVersions(db, []int{2, 9}); er == nil || len(order) != 0 {
+		t.Fatal(order, er)
+	}
+}
@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// moduleVersionTable is the name of the table holding a ModuleSchema's
+// per-module versions.
+const moduleVersionTable = "module_versions"
+
+// ModuleSchema combines the migrations of independent feature modules that
+// share a database but number their migrations separately. Each module
+// registers its migrations on its own Schema, obtained from Module, and has
+// its own row in the module_versions table; Install advances every module
+// within a single transaction.
+type ModuleSchema struct {
+	modules []moduleSchema
+	dialect Dialect
+}
+
+type moduleSchema struct {
+	name   string
+	schema *Schema
+}
+
+// SetDialect sets the Dialect used for the version table bookkeeping. Passing
+// nil restores the default, which is detected from the database's driver.
+func (s *ModuleSchema) SetDialect(d Dialect) {
+	s.dialect = d
+}
+
+// Module returns the Schema on which the module called name registers its
+// migrations, creating it on first use. Only the migrations registered on it
+// (with Update, UpdateSQL, AddDir and the like) are used; options configured on
+// it, as well as Down and Requires declarations, have no effect on
+// ModuleSchema.Install. Modules are installed in the order they were first
+// passed to Module.
+func (s *ModuleSchema) Module(name string) *Schema {
+	for _, m := range s.modules {
+		if m.name == name {
+			return m.schema
+		}
+	}
+
+	schema := &Schema{}
+	s.modules = append(s.modules, moduleSchema{name: name, schema: schema})
+	return schema
+}
+
+// Install applies, for every module, the migrations whose minVersion is newer
+// than the module's version in the database, in registration order, and
+// stamps the module with its highest registered minVersion. The version table
+// is created if needed, and everything happens in one transaction.
+func (s *ModuleSchema) Install(db *sql.DB) error {
+	ctx := context.Background()
+	d := (&Schema{dialect: s.dialect}).forDB(db).getDialect()
+
+	conn, er := db.Conn(ctx)
+	if er != nil {
+		return er
+	}
+	defer conn.Close()
+
+	if er := d.Lock(ctx, conn, moduleVersionTable); er != nil {
+		return er
+	}
+	defer d.Unlock(ctx, conn, moduleVersionTable)
+
+	if er := s.bootstrap(ctx, conn, d); er != nil {
+		return er
+	}
+
+	tx, er := conn.BeginTx(ctx, nil)
+	if er != nil {
+		return er
+	}
+	defer tx.Rollback()
+
+	for _, m := range s.modules {
+		if er := s.installModule(ctx, tx, d, m); er != nil {
+			return er
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *ModuleSchema) installModule(ctx context.Context, tx *sql.Tx, d Dialect, mod moduleSchema) error {
+	migrations, er := mod.schema.collect()
+	if er != nil {
+		return fmt.Errorf("migrate: module %s: %w", mod.name, er)
+	}
+
+	version, er := moduleVersion(ctx, tx, d, mod.name)
+	if er == sql.ErrNoRows {
+		version = 0
+		_, er = tx.ExecContext(ctx, "INSERT INTO "+moduleVersionTable+"(module, version) VALUES("+d.Placeholder(1)+", "+d.Placeholder(2)+")", mod.name, 0)
+	}
+	if er != nil {
+		return er
+	}
+
+	// The module's own options have no effect, so its migrations run as if
+	// on a bare Schema with the ModuleSchema's dialect.
+	run := &Schema{dialect: d}
+	for _, m := range migrations {
+		if m.minVersion <= version {
+			continue
+		}
+
+		if _, er := m.up(ctx, run, version, tx); er != nil {
+			return fmt.Errorf("migrate: module %s: migration %d: %w", mod.name, m.minVersion, er)
+		}
+	}
+
+	highest := highestVersion(migrations)
+	if highest <= version {
+		return nil
+	}
+
+	_, er = tx.ExecContext(ctx, "UPDATE "+moduleVersionTable+" SET version = "+d.Placeholder(1)+" WHERE module = "+d.Placeholder(2), highest, mod.name)
+	return er
+}
+
+// Version returns the version of module in db, or 0 if it has never been
+// installed.
+func (s *ModuleSchema) Version(db *sql.DB, module string) (int, error) {
+	d := (&Schema{dialect: s.dialect}).forDB(db).getDialect()
+
+	version, er := moduleVersion(context.Background(), db, d, module)
+	if er == sql.ErrNoRows || IsUndefinedObject(er) {
+		return 0, nil
+	}
+
+	return version, er
+}
+
+func moduleVersion(ctx context.Context, q Querier, d Dialect, module string) (int, error) {
+	var version int
+	er := q.QueryRowContext(ctx, "SELECT version FROM "+moduleVersionTable+" WHERE module = "+d.Placeholder(1), module).Scan(&version)
+	return version, er
+}
+
+// bootstrap creates the version table if it doesn't exist yet.
+func (s *ModuleSchema) bootstrap(ctx context.Context, db Querier, d Dialect) error {
+	exists, er := d.TableExists(ctx, db, moduleVersionTable)
+	if er != nil || exists {
+		return er
+	}
+
+	_, er = db.ExecContext(ctx, "CREATE TABLE "+moduleVersionTable+" (module VARCHAR(255) NOT NULL PRIMARY KEY, version INT NOT NULL)")
+	return er
+}
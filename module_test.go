@@ -0,0 +1,25 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestModuleSchema(t *testing.T) {
+	db := openDB(t)
+	var s ModuleSchema
+	s.Module("billing").UpdateSQL(1, "CREATE TABLE bill(x INT)")
+	s.Module("users").UpdateSQL(1, "CREATE TABLE usr(x INT)")
+	s.Module("users").UpdateSQL(2, "ALTER TABLE usr ADD y INT")
+	if er := s.Install(db); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db); er != nil {
+		t.Fatal(er)
+	}
+	if v, er := s.Version(db, "users"); v != 2 || er != nil {
+		t.Fatal(v, er)
+	}
+	if v, er := s.Version(db, "nope"); v != 0 || er != nil {
+		t.Fatal(v, er)
+	}
+}
@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// UpdateNoTx registers a migration that has to run outside of any transaction,
+// such as Postgres' CREATE INDEX CONCURRENTLY. The closure is passed the
+// database's version and the connection Install migrates on, which holds the
+// migration lock. Install commits the transactional migrations before it,
+// runs it on its own, stamps its minVersion in a transaction of its own and
+// then carries on with a fresh transaction, so the database's version always
+// reflects what has been committed. If it fails, whatever it did is left in
+// place and the version stays at the preceding migration; WithDirtyFlag marks
+// the database dirty while it runs. No other migration may share its
+// minVersion. Only Install (in any TxMode) and InstallContext can run it;
+// other ways of applying migrations, such as InstallResumable and Step, fail
+// when they reach it.
+func (s *Schema) UpdateNoTx(minVersion int, f func(int, DB) error) {
+	s.migrations = append(s.migrations, noTxMigration(minVersion, func(_ context.Context, _ *Schema, version int, conn DB) error {
+		return f(version, conn)
+	}))
+}
+
+// noTransaction is the marker that, anywhere in an up migration file, makes
+// AddDir register it as if with UpdateNoTx.
+const noTransaction = "-- migrate:no-transaction"
+
+func noTxMigration(minVersion int, f func(context.Context, *Schema, int, DB) error) migration {
+	return migration{
+		minVersion: minVersion,
+		noTx:       f,
+		up: func(context.Context, *Schema, int, *sql.Tx) (int64, error) {
+			return -1, fmt.Errorf("migrate: migration %d must run outside a transaction", minVersion)
+		},
+	}
+}
+
+// validateNoTx checks that no migration shares its minVersion with a
+// non-transactional one.
+func validateNoTx(migrations []migration) error {
+	count := make(map[int]int)
+	noTx := make(map[int]bool)
+
+	for _, m := range migrations {
+		count[m.minVersion]++
+		if m.noTx != nil {
+			noTx[m.minVersion] = true
+		}
+	}
+
+	for version := range noTx {
+		if count[version] > 1 {
+			return fmt.Errorf("migrate: non-transactional migration %d shares its minVersion with another migration", version)
+		}
+	}
+
+	return nil
+}
+
+// noTxPending reports whether a non-transactional migration is due to run
+// against the database's current version.
+func (s *Schema) noTxPending(ctx context.Context, conn DB, migrations []migration, maxVersion int) (bool, error) {
+	version, er := s.readVersion(ctx, conn)
+	if er != nil {
+		return false, er
+	}
+
+	for _, m := range migrations {
+		if m.noTx != nil && m.minVersion > version && m.minVersion <= maxVersion {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// applyNoTx runs the non-transactional migration m on conn. The checks and
+// bookkeeping that runMigration does within the migration transaction are
+// done in a transaction before it (setting the dirty flag) and one after it
+// (recording it and stamping its minVersion).
+func (s *Schema) applyNoTx(ctx context.Context, conn DB, migrations []migration, m migration, ran map[int]bool, res *Result) error {
+	skipped := len(res.Skipped)
+	run := false
+	var version int
+
+	er := s.transact(ctx, conn, func(tx *sql.Tx, current int) error {
+		res.Skipped = res.Skipped[:skipped]
+		version = current
+
+		if er := s.checkPrerequisites(m.minVersion, version, ran); er != nil {
+			return er
+		}
+
+		if er := s.checkCap(ctx, s.executor(tx), m.minVersion); er != nil {
+			return er
+		}
+
+		if !s.tagsActive(m.minVersion) {
+			if er := s.recordSkipped(ctx, tx, m); er != nil {
+				return er
+			}
+
+			s.logMigration(m, "skipped: none of its tags is active")
+			res.Skipped = append(res.Skipped, m.minVersion)
+			return s.setInstalledVersion(ctx, tx, migrations, version, m.minVersion)
+		}
+
+		if exists := s.exists[m.minVersion]; exists != nil {
+			present, er := exists(tx)
+			if er != nil {
+				return er
+			}
+
+			if present {
+				s.logMigration(m, "skipped: already applied")
+				res.Skipped = append(res.Skipped, m.minVersion)
+				return s.setInstalledVersion(ctx, tx, migrations, version, m.minVersion)
+			}
+		}
+
+		run = true
+		return s.setDirty(ctx, tx, true)
+	})
+	if er != nil {
+		return er
+	}
+
+	ran[m.minVersion] = true
+	if !run {
+		return nil
+	}
+
+	start := s.now()
+	after := s.beforeMigration(ctx, m, version, res)
+	er = m.noTx(ctx, s, version, conn)
+	after(s.now().Sub(start), er)
+	if er != nil {
+		return migrationError(ctx, m, res, er)
+	}
+
+	applied := AppliedMigration{
+		Version:      m.minVersion,
+		Name:         m.name,
+		Description:  m.description,
+		RowsAffected: -1,
+		Duration:     s.now().Sub(start),
+		Checksum:     m.hash(),
+	}
+
+	er = s.transact(ctx, conn, func(tx *sql.Tx, current int) error {
+		if er := s.recordHistory(ctx, tx, applied); er != nil {
+			return er
+		}
+
+		if er := s.recordEvent(ctx, tx, m.minVersion, "up", m.name); er != nil {
+			return er
+		}
+
+		return s.setInstalledVersion(ctx, tx, migrations, current, m.minVersion)
+	})
+	if er != nil {
+		return er
+	}
+
+	res.Applied = append(res.Applied, applied)
+	s.logMigration(m, "applied in %v", applied.Duration)
+	return nil
+}
@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestNoTx(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.UpdateSQL(1, "CREATE TABLE n1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE n2(x INT)")
+	fail := true
+	s.UpdateNoTx(3, func(v int, conn DB) error {
+		if v != 2 {
+			t.Errorf("version %d", v)
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		_, er := conn.ExecContext(context.Background(), "CREATE INDEX n2x ON n2(x)")
+		return er
+	})
+	s.UpdateSQL(4, "CREATE TABLE n4(x INT)")
+	var me *MigrationError
+	if er := s.Install(db, 4); !errors.As(er, &me) || me.Version != 3 {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+	fail = false
+	res, er := s.InstallResult(db, 4)
+	if er != nil || res.From != 2 || res.To != 4 || len(res.Applied) != 2 {
+		t.Fatal(res, er)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM migration_history").Scan(&n)
+	if n != 4 {
+		t.Fatal(n)
+	}
+	s.Update(4, func(v int, tx *sql.Tx) error { return nil })
+	s.UpdateNoTx(4, func(v int, conn DB) error { return nil })
+	if er := s.Validate(); er == nil {
+		t.Fatal("expected shared minVersion error")
+	}
+}
@@ -0,0 +1,36 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// OpenAndInstall opens the database with sql.Open, pings it and installs schema
+// up to maxVersion, returning the open database for the caller to use (and
+// close). Unless schema already has a Dialect, it installs with the one
+// DialectFor returns for driverName, leaving schema itself unchanged. Errors
+// are wrapped with the stage that failed; on error the database is closed and
+// nil is returned.
+func OpenAndInstall(driverName, dsn string, schema *Schema, maxVersion int) (*sql.DB, error) {
+	db, er := sql.Open(driverName, dsn)
+	if er != nil {
+		return nil, fmt.Errorf("migrate: open: %w", er)
+	}
+
+	if er := db.Ping(); er != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: ping: %w", er)
+	}
+
+	if schema.dialect == nil {
+		schema = schema.clone()
+		schema.dialect = DialectFor(driverName)
+	}
+
+	if er := schema.Install(db, maxVersion); er != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: install: %w", er)
+	}
+
+	return db, nil
+}
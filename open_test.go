@@ -0,0 +1,33 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenAndInstall(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "open.db")
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE oi1(x INT)")
+	db, er := OpenAndInstall("sqlite", dsn, &s, 1)
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer db.Close()
+	if v, er := Version(db); er != nil || v != 1 {
+		t.Fatal(v, er)
+	}
+	if s.dialect != nil {
+		t.Fatal("schema's dialect was set")
+	}
+
+	if _, er := OpenAndInstall("nope", dsn, &s, 1); er == nil || !strings.HasPrefix(er.Error(), "migrate: open: ") {
+		t.Fatal(er)
+	}
+
+	s.UpdateSQL(2, "CREATE TABLE nope nope")
+	if db, er := OpenAndInstall("sqlite", dsn, &s, 2); db != nil || er == nil || !strings.HasPrefix(er.Error(), "migrate: install: ") {
+		t.Fatal(db, er)
+	}
+}
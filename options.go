@@ -0,0 +1,276 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Option configures optional behaviour of a Schema. Options are applied with
+// Schema.Configure.
+type Option func(*Schema)
+
+// Configure applies each of the passed options to the receiving Schema.
+func (s *Schema) Configure(opts ...Option) {
+	for _, opt := range opts {
+		opt(s)
+	}
+}
+
+// WithClock sets the time source used for every timestamp migrate records. It
+// defaults to time.Now; tests may inject a fixed clock to get reproducible
+// values.
+func WithClock(now func() time.Time) Option {
+	return func(s *Schema) {
+		s.clock = now
+	}
+}
+
+// WithBootstrapSQL replaces the statements used to create the version table
+// and to insert its single row, e.g. to control its indexes or storage
+// parameters. createSQL is executed when the table doesn't exist; seedSQL
+// whenever the row is missing, with the initial version as its only bind
+// parameter. Detecting and reading the table is still up to migrate, so the
+// table must be named "version" and have an integer "version" column; any
+// other columns must be nullable or have defaults. Either statement may be ""
+// to keep the built-in one.
+func WithBootstrapSQL(createSQL, seedSQL string) Option {
+	return func(s *Schema) {
+		s.createSQL = createSQL
+		s.seedSQL = seedSQL
+	}
+}
+
+// WithVersionColumns sets values for extra columns of the version table, which
+// are included whenever migrate inserts the version row. Columns it isn't
+// given values for must be nullable or have defaults; migrate only ever
+// updates the version column (and the dirty column, see WithDirtyFlag). The
+// column names are used verbatim.
+func WithVersionColumns(values map[string]interface{}) Option {
+	return func(s *Schema) {
+		s.versionColumns = values
+	}
+}
+
+// TxMode selects how Schema.Install groups migrations into transactions.
+type TxMode int
+
+const (
+	// TxAuto uses TxSingle if the Dialect reports transactional DDL, and
+	// TxPerMigration otherwise. It is the default.
+	TxAuto TxMode = iota
+
+	// TxSingle applies every pending migration, and the final version stamp,
+	// in one transaction: either all of them take effect or none do. A
+	// pending migration registered with UpdateNoTx splits the transaction,
+	// the migrations before it being committed first.
+	TxSingle
+
+	// TxPerMigration commits each migration (or group of migrations sharing
+	// a minVersion) in its own transaction along with a stamp of its
+	// minVersion. A failed run leaves the database at the last successful
+	// migration, from which the next Install resumes. This is the safer mode
+	// for databases whose DDL can't be rolled back, such as MySQL.
+	TxPerMigration
+)
+
+// WithTxMode overrides how migrations are grouped into transactions.
+func WithTxMode(mode TxMode) Option {
+	return func(s *Schema) {
+		s.txMode = mode
+	}
+}
+
+// WithTxOptions sets the options, such as the isolation level, that migration
+// transactions are begun with.
+func WithTxOptions(opts *sql.TxOptions) Option {
+	return func(s *Schema) {
+		s.txOptions = opts
+	}
+}
+
+// WithRetry makes a migration transaction that fails with a serialization
+// failure or a deadlock (see IsSerializationFailure and IsDeadlock), both of
+// which are safe to retry, be retried up to retries times. The first retry
+// waits backoff, and each subsequent one twice as long as the last. The whole
+// transaction is rerun, migrations included; errors of any other kind are
+// never retried.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(s *Schema) {
+		s.retries = retries
+		s.retryBackoff = backoff
+	}
+}
+
+// WithBatchSize makes Install commit after every n minVersions' worth of
+// migrations, stamping the highest minVersion of each batch, rather than
+// applying everything in one transaction. This bounds the size of the
+// transaction on a fresh install of a long migration chain while keeping most
+// of its atomicity; a failed run leaves the database at the last committed
+// batch, from which the next Install resumes. n <= 0 restores the default of a
+// single batch. It has no effect when migrations are applied with
+// TxPerMigration, which is equivalent to a batch size of 1.
+func WithBatchSize(n int) Option {
+	return func(s *Schema) {
+		s.batchSize = n
+	}
+}
+
+func (s *Schema) perMigration() bool {
+	switch s.txMode {
+	case TxSingle:
+		return false
+
+	case TxPerMigration:
+		return true
+	}
+
+	return !s.getDialect().TransactionalDDL()
+}
+
+func (s *Schema) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+
+	return s.clock()
+}
+
+// WithDatabase makes Install switch the connection it migrates on to database
+// (with USE) before doing anything else, for servers hosting several
+// databases where the connection's default isn't the one the schema lives in.
+// It only has an effect with DialectMySQL and DialectSQLServer, the generic
+// dialect having no such statement; helpers that only read from the database,
+// such as Plan and Version, still use the connection's default, and it can't
+// be combined with DetectExistingVersion or WithFreshCheck, whose probes are
+// passed the *sql.DB. As the name is interpolated into SQL, anything but
+// letters, digits and underscores is rejected.
+func WithDatabase(database string) Option {
+	return func(s *Schema) {
+		s.database = database
+	}
+}
+
+// useDatabase switches q to the database set with WithDatabase, if any.
+func (s *Schema) useDatabase(ctx context.Context, q Querier) error {
+	if s.database == "" {
+		return nil
+	}
+
+	if !tableName.MatchString(s.database) {
+		return fmt.Errorf("migrate: invalid database name %q", s.database)
+	}
+
+	var query string
+	switch s.getDialect().(type) {
+	case mysqlDialect:
+		query = "USE `" + s.database + "`"
+
+	case sqlServerDialect:
+		query = "USE [" + s.database + "]"
+
+	default:
+		return nil
+	}
+
+	_, er := q.ExecContext(ctx, query)
+	return er
+}
+
+// AdoptFrom eases switching to migrate from another migration tool. When the
+// version table does not exist yet, its initial version is read from column of
+// legacyTable (the largest value, if there are several rows) instead of
+// starting at 0. Once the version table has been created the legacy table is
+// never consulted again. If legacyTable doesn't exist either, the database is
+// treated as fresh.
+func AdoptFrom(legacyTable, column string) Option {
+	return func(s *Schema) {
+		s.adoptTable = legacyTable
+		s.adoptColumn = column
+	}
+}
+
+// DetectExistingVersion sets a probe that is consulted when the version table
+// has to be created, to work out the version of a database that predates
+// migrate (for example by checking which tables exist). If the probe reports
+// ok, the version table is seeded with its version rather than 0. A version
+// found via AdoptFrom takes precedence over the probe.
+func DetectExistingVersion(probe func(*sql.DB) (version int, ok bool, er error)) Option {
+	return func(s *Schema) {
+		s.detectVersion = probe
+	}
+}
+
+// ErrNotFresh is returned when the version table has to be created, no earlier
+// version was found via AdoptFrom or DetectExistingVersion, and the probe set
+// with WithFreshCheck reports that the database isn't empty.
+var ErrNotFresh = errors.New("migrate: database has no version table but is not fresh")
+
+// WithFreshCheck sets a probe that decides whether a database without a version
+// table is genuinely fresh, e.g. by checking that it has no tables at all. By
+// default any database without a version table (and no version found via
+// AdoptFrom or DetectExistingVersion) is taken to be at version 0, so a
+// populated legacy database would have every migration, and any Checkpoint,
+// run against it. With the probe, such a database is refused with ErrNotFresh
+// instead, before anything is created.
+func WithFreshCheck(isFresh func(*sql.DB) (bool, error)) Option {
+	return func(s *Schema) {
+		s.isFresh = isFresh
+	}
+}
+
+// initialVersion returns the version a newly created version table is seeded
+// with.
+func (s *Schema) initialVersion(ctx context.Context, db DB) (int, error) {
+	if s.adoptTable != "" {
+		exists, er := s.getDialect().TableExists(ctx, db, s.adoptTable)
+		if er != nil {
+			return 0, er
+		}
+
+		if exists {
+			var version sql.NullInt64
+			if er := db.QueryRowContext(ctx, "SELECT MAX("+s.adoptColumn+") FROM "+s.adoptTable).Scan(&version); er != nil {
+				return 0, er
+			}
+
+			return int(version.Int64), nil
+		}
+	}
+
+	if s.detectVersion != nil {
+		sqlDB, ok := db.(*sql.DB)
+		if !ok {
+			return 0, errors.New("migrate: DetectExistingVersion needs a *sql.DB")
+		}
+
+		version, ok, er := s.detectVersion(sqlDB)
+		if er != nil {
+			return 0, er
+		}
+
+		if ok {
+			return version, nil
+		}
+	}
+
+	if s.isFresh != nil {
+		sqlDB, ok := db.(*sql.DB)
+		if !ok {
+			return 0, errors.New("migrate: WithFreshCheck needs a *sql.DB")
+		}
+
+		fresh, er := s.isFresh(sqlDB)
+		if er != nil {
+			return 0, er
+		}
+
+		if !fresh {
+			return 0, ErrNotFresh
+		}
+	}
+
+	return 0, nil
+}
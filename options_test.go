@@ -0,0 +1,230 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdoptFrom(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE db_version(rev INT)")
+	db.Exec("INSERT INTO db_version VALUES (1), (2)")
+	var s Schema
+	s.Configure(AdoptFrom("db_version", "rev"))
+	s.UpdateSQL(1, "CREATE TABLE ad1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE ad2(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE ad3(x INT)")
+	if er := s.Install(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM ad2"); er == nil {
+		t.Fatal("adopted migration re-applied")
+	}
+	if _, er := db.Exec("SELECT * FROM ad3"); er != nil {
+		t.Fatal(er)
+	}
+
+	db.Exec("INSERT INTO db_version VALUES (9)")
+	s.UpdateSQL(4, "CREATE TABLE ad4(x INT)")
+	if er := s.Install(db, 4); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 4 {
+		t.Fatal(v)
+	}
+
+	fresh := openDB(t)
+	if er := s.Install(fresh, 4); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := fresh.Exec("SELECT * FROM ad1"); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestDetectExistingVersion(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE dv1(x INT)")
+	probes := 0
+	var s Schema
+	s.Configure(DetectExistingVersion(func(db *sql.DB) (int, bool, error) {
+		probes++
+		var n int
+		er := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'dv1'").Scan(&n)
+		return 1, n == 1, er
+	}))
+	s.UpdateSQL(1, "CREATE TABLE dv1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE dv2(x INT)")
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM dv2"); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 2); er != nil || probes != 1 {
+		t.Fatal(probes, er)
+	}
+
+	fresh := openDB(t)
+	if er := s.Install(fresh, 2); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(fresh); v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestBootstrapSQL(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithBootstrapSQL(
+		"CREATE TABLE version (version INT NOT NULL, note TEXT DEFAULT 'custom')",
+		"INSERT INTO version (version, note) VALUES (?, 'seeded')",
+	))
+	s.UpdateSQL(1, "CREATE TABLE bs1(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	var note string
+	if er := db.QueryRow("SELECT note FROM version").Scan(&note); er != nil || note != "seeded" {
+		t.Fatal(note, er)
+	}
+	if v, _ := Version(db); v != 1 {
+		t.Fatal(v)
+	}
+}
+
+func TestVersionColumns(t *testing.T) {
+	db := openDB(t)
+	db.Exec("CREATE TABLE version (version INT NOT NULL, app TEXT NOT NULL, note TEXT)")
+	var s Schema
+	s.Configure(WithVersionColumns(map[string]interface{}{"app": "billing"}))
+	s.UpdateSQL(1, "CREATE TABLE vcol1(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	s.UpdateSQL(2, "CREATE TABLE vcol2(x INT)")
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+
+	var version int
+	var app string
+	var note sql.NullString
+	if er := db.QueryRow("SELECT version, app, note FROM version").Scan(&version, &app, &note); er != nil || version != 2 || app != "billing" || note.Valid {
+		t.Fatal(version, app, note, er)
+	}
+}
+
+// txOptsDB is a DB that records the options its transactions are begun with.
+type txOptsDB struct {
+	Querier
+	db   *sql.DB
+	opts []*sql.TxOptions
+}
+
+func (d *txOptsDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	d.opts = append(d.opts, opts)
+	return d.db.BeginTx(ctx, opts)
+}
+
+func TestTxOptions(t *testing.T) {
+	sqlDB := openDB(t)
+	db := &txOptsDB{Querier: sqlDB, db: sqlDB}
+	opts := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	var s Schema
+	s.Configure(WithTxOptions(opts))
+	s.SetDialect(DialectSQLite)
+	s.UpdateSQL(1, "CREATE TABLE to1(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if len(db.opts) == 0 {
+		t.Fatal("no transaction begun")
+	}
+	for _, o := range db.opts {
+		if o != opts {
+			t.Fatal(o)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	db := openDB(t)
+	attempts := 0
+	var s Schema
+	s.Configure(WithRetry(2, time.Millisecond))
+	s.Update(1, func(v int, tx *sql.Tx) error {
+		attempts++
+		if attempts < 3 {
+			return &pqError{"40001"}
+		}
+		_, er := tx.Exec("CREATE TABLE rt1(x INT)")
+		return er
+	})
+	if er := s.Install(db, 1); er != nil || attempts != 3 {
+		t.Fatal(attempts, er)
+	}
+
+	attempts = 0
+	s.Update(2, func(int, *sql.Tx) error { attempts++; return &pqError{"40P01"} })
+	if er := s.Install(db, 2); !IsDeadlock(er) || attempts != 3 {
+		t.Fatal(attempts, er)
+	}
+
+	attempts = 0
+	var other Schema
+	other.Configure(WithRetry(2, time.Millisecond))
+	other.Update(1, func(int, *sql.Tx) error { attempts++; return errors.New("boom") })
+	if er := other.Install(openDB(t), 1); er == nil || attempts != 1 {
+		t.Fatal(attempts, er)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithDatabase("billing"))
+	s.UpdateSQL(1, "CREATE TABLE wd1(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+
+	// SQLite has no USE statement, so switching fails before anything is
+	// created.
+	db = openDB(t)
+	s.SetDialect(DialectMySQL)
+	if er := s.Install(db, 1); er == nil {
+		t.Fatal("expected USE to fail")
+	}
+	if _, er := Version(db); !errors.Is(er, ErrNoVersionTable) {
+		t.Fatal(er)
+	}
+
+	s.Configure(WithDatabase("billing; DROP TABLE x"))
+	if er := s.Install(db, 1); er == nil || !strings.Contains(er.Error(), "invalid database name") {
+		t.Fatal(er)
+	}
+}
+
+func TestClock(t *testing.T) {
+	db := openDB(t)
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var s Schema
+	s.Configure(WithHistory(), WithClock(func() time.Time { return at }))
+	s.UpdateSQL(1, "CREATE TABLE ck(x INT)")
+	res, er := s.InstallResult(db, 1)
+	if er != nil || res.Applied[0].Duration != 0 {
+		t.Fatal(res, er)
+	}
+
+	var applied time.Time
+	var duration int64
+	if er := db.QueryRow("SELECT applied_at, duration_ms FROM migration_history").Scan(&applied, &duration); er != nil || !applied.Equal(at) || duration != 0 {
+		t.Fatal(applied, duration, er)
+	}
+}
@@ -0,0 +1,143 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// OutOfOrder says what Install does about migrations registered with a
+// minVersion no greater than the database's version that were never applied,
+// as happens when a branch adding migration 41 merges after another branch's
+// migration 42 has been deployed. See WithOutOfOrder.
+type OutOfOrder int
+
+const (
+	// OutOfOrderIgnore skips such migrations, as Install always has.
+	OutOfOrderIgnore OutOfOrder = iota
+
+	// OutOfOrderFail makes Install fail with an OutOfOrderError listing
+	// them, before migrating anything.
+	OutOfOrderFail
+
+	// OutOfOrderApply makes Install apply them, oldest first and before
+	// any newer migrations, in the same transaction as the first of those.
+	// They are reported in Result.Applied like any other migration.
+	OutOfOrderApply
+)
+
+// OutOfOrderError is returned by Install under OutOfOrderFail when migrations
+// below the database's version were never applied.
+type OutOfOrderError struct {
+	Versions []int
+}
+
+func (e *OutOfOrderError) Error() string {
+	return fmt.Sprintf("migrate: migrations %v are below the database's version but were never applied", e.Versions)
+}
+
+// WithOutOfOrder sets how Install handles missed migrations, and implies
+// WithHistory, whose rows are what tell migrate that a migration was applied.
+// Only versions above the lowest version in the history table are considered,
+// so that the migrations of a database that predates the history table (or was
+// started from a Checkpoint) don't all look missed, and migrations skipped for
+// their tags are left alone. A migration skipped by its AlreadyApplied
+// predicate has no history row either, and is considered again on every run.
+func WithOutOfOrder(mode OutOfOrder) Option {
+	return func(s *Schema) {
+		s.history = true
+		s.outOfOrder = mode
+	}
+}
+
+// missedVersions returns the distinct registered minVersions no greater than
+// version that have no history row, in ascending order, as described for
+// WithOutOfOrder. A migration reverted by DownOne keeps its (reverted) rows, so
+// isn't missed.
+func (s *Schema) missedVersions(ctx context.Context, q Querier, migrations []migration, version int) ([]int, error) {
+	rows, er := q.QueryContext(ctx, "SELECT DISTINCT version FROM "+s.historyTable())
+	if er != nil {
+		return nil, er
+	}
+	defer rows.Close()
+
+	recorded := make(map[int]bool)
+	lowest := 0
+	for rows.Next() {
+		var v int
+		if er := rows.Scan(&v); er != nil {
+			return nil, er
+		}
+
+		recorded[v] = true
+		if lowest == 0 || v < lowest {
+			lowest = v
+		}
+	}
+
+	if er := rows.Err(); er != nil || lowest == 0 {
+		return nil, er
+	}
+
+	var missed []int
+	for _, m := range migrations {
+		v := m.minVersion
+		if v > lowest && v <= version && !recorded[v] && s.tagsActive(v) {
+			recorded[v] = true
+			missed = append(missed, v)
+		}
+	}
+
+	sort.Ints(missed)
+	return missed, nil
+}
+
+// checkOutOfOrder returns an OutOfOrderError under OutOfOrderFail if any
+// migrations were missed.
+func (s *Schema) checkOutOfOrder(ctx context.Context, q Querier, migrations []migration) error {
+	if s.outOfOrder != OutOfOrderFail {
+		return nil
+	}
+
+	version, er := s.readVersion(ctx, q)
+	if er != nil {
+		return er
+	}
+
+	missed, er := s.missedVersions(ctx, q, migrations, version)
+	if er != nil || len(missed) == 0 {
+		return er
+	}
+
+	return &OutOfOrderError{Versions: missed}
+}
+
+// applyMissed runs the missed migrations in tx under OutOfOrderApply.
+func (s *Schema) applyMissed(ctx context.Context, tx *sql.Tx, migrations []migration, version int, ran map[int]bool, res *Result) error {
+	if s.outOfOrder != OutOfOrderApply {
+		return nil
+	}
+
+	missed, er := s.missedVersions(ctx, s.executor(tx), migrations, version)
+	if er != nil || len(missed) == 0 {
+		return er
+	}
+
+	log.Printf("migrate: applying migrations %v out of order to a database at version %d", missed, version)
+
+	for _, v := range missed {
+		for _, m := range migrations {
+			if m.minVersion != v {
+				continue
+			}
+
+			if er := s.runMigration(ctx, tx, version, m, ran, res); er != nil {
+				return er
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutOfOrder(t *testing.T) {
+	for _, mode := range []TxMode{TxSingle, TxPerMigration} {
+		db := openDB(t)
+		var s Schema
+		s.SetDialect(DialectSQLite)
+		s.Configure(WithOutOfOrder(OutOfOrderFail), WithTxMode(mode))
+		s.UpdateSQL(1, "CREATE TABLE oo1(x INT)")
+		s.UpdateSQL(3, "CREATE TABLE oo3(x INT)")
+		if er := s.Install(db, 3); er != nil {
+			t.Fatal(er)
+		}
+		s.migrations = nil
+		s.UpdateSQL(1, "CREATE TABLE oo1(x INT)")
+		s.UpdateSQL(2, "CREATE TABLE oo2(x INT)")
+		s.UpdateSQL(3, "CREATE TABLE oo3(x INT)")
+		s.UpdateSQL(4, "CREATE TABLE oo4(x INT)")
+		var oe *OutOfOrderError
+		if er := s.Install(db, 4); !errors.As(er, &oe) || len(oe.Versions) != 1 || oe.Versions[0] != 2 {
+			t.Fatal(er)
+		}
+		s.Configure(WithOutOfOrder(OutOfOrderApply))
+		res, er := s.InstallResult(db, 4)
+		if er != nil || len(res.Applied) != 2 || res.Applied[0].Version != 2 || res.To != 4 {
+			t.Fatal(er, res)
+		}
+		if _, er := db.Exec("SELECT * FROM oo2"); er != nil {
+			t.Fatal(er)
+		}
+		res, er = s.InstallResult(db, 4)
+		if er != nil || len(res.Applied) != 0 {
+			t.Fatal(er, res)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// PermissionError is returned by Schema.CheckPermissions when the database
+// user lacks a privilege that migrations typically need.
+type PermissionError struct {
+	// Privilege is CREATE, ALTER or DROP.
+	Privilege string
+	Err       error
+}
+
+func (e *PermissionError) Error() string {
+	return "migrate: missing " + e.Privilege + " privilege: " + e.Err.Error()
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// CheckPermissions checks that the database user can create, alter and drop
+// tables, so that a missing privilege is reported at startup rather than part
+// way through a migration. It probes by creating, altering and dropping a
+// scratch table, migrate_permission_probe, inside a transaction that is then
+// rolled back; on an RDBMS without transactional DDL the table is dropped
+// explicitly instead.
+func (s *Schema) CheckPermissions(db *sql.DB) error {
+	s = s.forDB(db)
+	const probe = "migrate_permission_probe"
+	ctx := context.Background()
+
+	tx, er := db.BeginTx(ctx, nil)
+	if er != nil {
+		return er
+	}
+	defer tx.Rollback()
+
+	if _, er := tx.ExecContext(ctx, "CREATE TABLE "+probe+" (x INT)"); er != nil {
+		return &PermissionError{Privilege: "CREATE", Err: er}
+	}
+
+	if _, er := tx.ExecContext(ctx, "ALTER TABLE "+probe+" ADD y INT"); er != nil {
+		if !s.getDialect().TransactionalDDL() {
+			tx.ExecContext(ctx, "DROP TABLE "+probe)
+		}
+
+		return &PermissionError{Privilege: "ALTER", Err: er}
+	}
+
+	if _, er := tx.ExecContext(ctx, "DROP TABLE "+probe); er != nil {
+		return &PermissionError{Privilege: "DROP", Err: er}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	if er := s.CheckPermissions(db); er != nil {
+		t.Fatal(er)
+	}
+	var n int
+	if er := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'migrate_permission_probe'").Scan(&n); er != nil || n != 0 {
+		t.Fatal(n, er)
+	}
+
+	path := filepath.Join(t.TempDir(), "ro.db")
+	rw, er := sql.Open("sqlite", path)
+	if er != nil {
+		t.Fatal(er)
+	}
+	rw.Exec("CREATE TABLE t(x INT)")
+	rw.Close()
+	ro, er := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer ro.Close()
+	var pe *PermissionError
+	if er := s.CheckPermissions(ro); !errors.As(er, &pe) || pe.Privilege != "CREATE" {
+		t.Fatal(er)
+	}
+}
@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Plan describes what Schema.Install would do to a database.
+type Plan struct {
+	// Current is the database's version, and Target the version it would be
+	// stamped with.
+	Current, Target int
+
+	// Migrations lists the migrations that would run, in order.
+	Migrations []PlannedMigration
+}
+
+// PlannedMigration describes a single migration in a Plan.
+type PlannedMigration struct {
+	Version     int
+	Name        string
+	Description string
+
+	// Skipped is set if the migration is pending but would be skipped
+	// because none of its tags is in the active environment.
+	Skipped bool
+
+	// Seed is set for migrations registered with Schema.Seed.
+	Seed bool
+
+	// SQL is the migration's SQL, empty for closures and for files too large
+	// for AddDir to hold in memory, and Statements the statements into which
+	// UpdateSQL and AddDir split it to execute them one at a time.
+	SQL        string
+	Statements []string
+}
+
+// Plan works out what Install(db, maxVersion) would do without doing it. It
+// only reads from the database; in particular a missing version table is not
+// created, the database is instead treated as being at the version a new
+// version table would be seeded with.
+func (s *Schema) Plan(db *sql.DB, maxVersion int) (*Plan, error) {
+	s = s.forDB(db)
+	ctx := context.Background()
+
+	migrations, er := s.collect()
+	if er != nil {
+		return nil, er
+	}
+
+	exists, er := s.getDialect().TableExists(ctx, db, s.stateTable())
+	if er != nil {
+		return nil, er
+	}
+
+	var version int
+	if exists {
+		version, er = s.readVersion(ctx, db)
+
+	} else {
+		version, er = s.initialVersion(ctx, db)
+	}
+	if er != nil {
+		return nil, er
+	}
+
+	plan := &Plan{Current: version, Target: maxVersion}
+
+	if version == 0 && s.checkpoint != nil && s.checkpoint.minVersion <= maxVersion {
+		plan.Migrations = append(plan.Migrations, PlannedMigration{Version: s.checkpoint.minVersion})
+		version = s.checkpoint.minVersion
+	}
+
+	for _, m := range migrations {
+		if m.minVersion > version && m.minVersion <= maxVersion {
+			plan.Migrations = append(plan.Migrations, PlannedMigration{
+				Version:     m.minVersion,
+				Name:        m.name,
+				Description: m.description,
+				Skipped:     !s.tagsActive(m.minVersion),
+				Seed:        m.seed,
+				SQL:         m.sql,
+				Statements:  splitStatements(m.sql),
+			})
+		}
+	}
+
+	ran := &Result{}
+	for _, m := range plan.Migrations {
+		ran.Applied = append(ran.Applied, AppliedMigration{Version: m.Version})
+	}
+
+	plan.Target = s.stampVersion(migrations, plan.Current, maxVersion, ran)
+	if len(plan.Migrations) == 0 && plan.Current >= plan.Target {
+		plan.Target = plan.Current
+	}
+
+	return plan, nil
+}
+
+// WithDryRun makes Install run the pending migrations exactly as it otherwise
+// would, in a single transaction, and then roll that transaction back, so that
+// a deploy can be rehearsed against a copy of production data. The Result
+// describes the run, but none of it is kept: AfterCommit hooks don't run and
+// the AuditSink is told nothing. migrate's own bookkeeping tables are still
+// created if they don't exist yet. Since DDL can't be rolled back on every
+// database, Install refuses to dry-run on a Dialect without transactional DDL,
+// and when a non-transactional migration (see UpdateNoTx) is pending.
+func WithDryRun() Option {
+	return func(s *Schema) {
+		s.dryRun = true
+	}
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("migrate: dry run")
+
+// applyDryRun is migrate for WithDryRun.
+func (s *Schema) applyDryRun(ctx context.Context, conn DB, migrations []migration, maxVersion int, res *Result) error {
+	if !s.getDialect().TransactionalDDL() {
+		return errors.New("migrate: can't dry-run without transactional DDL")
+	}
+
+	if noTx, er := s.noTxPending(ctx, conn, migrations, maxVersion); er != nil {
+		return er
+
+	} else if noTx {
+		return errors.New("migrate: can't dry-run a non-transactional migration")
+	}
+
+	er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+		if er := s.apply(ctx, tx, migrations, version, maxVersion, res); er != nil {
+			return er
+		}
+
+		return errDryRun
+	})
+	if er == errDryRun {
+		return nil
+	}
+
+	return er
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrPlanMismatch is returned by Install when WithPlanHash is enabled and the
+// migrations that took the database to its current version differ from those
+// this binary has registered up to that version.
+var ErrPlanMismatch = errors.New("migrate: database was migrated with a different set of migrations")
+
+// WithPlanHash detects databases touched by a binary with a different set of
+// migrations, as can happen while a rolling deploy runs two versions side by
+// side. Whenever Install stamps a version, a hash of every migration up to
+// that version (see Migration.Hash) is stored in a nullable `plan_hash` column
+// of the version table, which is added if needed. Before migrating, Install
+// compares it with the hash of this binary's migrations up to the database's
+// version, returning ErrPlanMismatch if they differ. The stored hash is
+// cleared when the version is stamped other than by migrating (by Rollback or
+// ForceClean, say), so the next Install doesn't check. This option can't be
+// combined with WithVersionLog.
+func WithPlanHash() Option {
+	return func(s *Schema) {
+		s.planHash = true
+	}
+}
+
+// planHash returns the hash of the migrations with a minVersion no greater
+// than version.
+func planHash(migrations []migration, version int) string {
+	h := sha256.New()
+	for _, m := range migrations {
+		if m.minVersion <= version {
+			h.Write([]byte(m.hash() + "\n"))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkPlanHash returns ErrPlanMismatch if plan hashes are enabled and the
+// stored hash doesn't match migrations.
+func (s *Schema) checkPlanHash(ctx context.Context, q Querier, migrations []migration) error {
+	if !s.planHash {
+		return nil
+	}
+
+	var version int
+	var stored sql.NullString
+	er := q.QueryRowContext(ctx, "SELECT version, plan_hash FROM "+versionTable).Scan(&version, &stored)
+	if er == sql.ErrNoRows {
+		return nil
+	}
+
+	if er != nil {
+		return er
+	}
+
+	if stored.Valid && stored.String != planHash(migrations, version) {
+		return fmt.Errorf("%w: up to version %d", ErrPlanMismatch, version)
+	}
+
+	return nil
+}
+
+// setPlanHash stores the hash of migrations up to version, or clears the
+// stored hash if migrations is nil.
+func (s *Schema) setPlanHash(ctx context.Context, tx *sql.Tx, migrations []migration, version int) error {
+	if !s.planHash {
+		return nil
+	}
+
+	var hash sql.NullString
+	if migrations != nil {
+		hash = sql.NullString{String: planHash(migrations, version), Valid: true}
+	}
+
+	_, er := s.executor(tx).ExecContext(ctx, "UPDATE "+versionTable+" SET plan_hash = "+s.getDialect().Placeholder(1), hash)
+	return er
+}
+
+// setInstalledVersion is setDbVersion for versions reached by applying
+// migrations, additionally storing their plan hash.
+func (s *Schema) setInstalledVersion(ctx context.Context, tx *sql.Tx, migrations []migration, expected, version int) error {
+	if er := s.setDbVersion(ctx, tx, expected, version); er != nil {
+		return er
+	}
+
+	return s.setPlanHash(ctx, tx, migrations, version)
+}
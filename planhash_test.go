@@ -0,0 +1,27 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlanHash(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithPlanHash())
+	s.UpdateSQL(1, "CREATE TABLE ph(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE ph2(x INT)")
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	var other Schema
+	other.Configure(WithPlanHash())
+	other.UpdateSQL(1, "CREATE TABLE ph(x INT)")
+	other.UpdateSQL(2, "CREATE TABLE ph3(x INT)")
+	if er := other.Install(db, 2); !errors.Is(er, ErrPlanMismatch) {
+		t.Fatal(er)
+	}
+}
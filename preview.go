@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ImpactEstimate is the number of rows a pending migration is expected to
+// affect, as reported by Schema.PreviewImpact.
+type ImpactEstimate struct {
+	Version int
+	Name    string
+	Rows    int64
+}
+
+// Preview registers query as the preview of the migration registered with
+// minVersion: a query returning a single count of the rows the migration would
+// affect, typically its UPDATE or DELETE's WHERE clause as a
+// SELECT COUNT(*). It is only run by Schema.PreviewImpact.
+func (s *Schema) Preview(minVersion int, query string) {
+	if s.previews == nil {
+		s.previews = make(map[int]string)
+	}
+
+	s.previews[minVersion] = query
+}
+
+// PreviewImpact runs the preview query of every pending migration that has one
+// (see Schema.Preview) and returns their estimates in migration order. The
+// pending migrations are those Plan reports for the highest registered
+// version, and nothing is applied, so a preview sees the database as it is
+// now rather than as the earlier pending migrations would leave it. The
+// queries run in a transaction that is always rolled back.
+func (s *Schema) PreviewImpact(db *sql.DB) ([]ImpactEstimate, error) {
+	s = s.forDB(db)
+	migrations, er := s.collect()
+	if er != nil {
+		return nil, er
+	}
+
+	plan, er := s.Plan(db, s.latestVersion(migrations))
+	if er != nil {
+		return nil, er
+	}
+
+	ctx := context.Background()
+
+	tx, er := db.BeginTx(ctx, s.txOptions)
+	if er != nil {
+		return nil, er
+	}
+	defer tx.Rollback()
+
+	var estimates []ImpactEstimate
+	for _, m := range plan.Migrations {
+		query, ok := s.previews[m.Version]
+		if !ok || m.Skipped {
+			continue
+		}
+
+		estimate := ImpactEstimate{Version: m.Version, Name: m.Name}
+		if er := tx.QueryRowContext(ctx, query).Scan(&estimate.Rows); er != nil {
+			return nil, fmt.Errorf("migrate: preview of migration %d: %w", m.Version, er)
+		}
+
+		estimates = append(estimates, estimate)
+	}
+
+	return estimates, nil
+}
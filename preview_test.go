@@ -0,0 +1,20 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE p(x INT); INSERT INTO p VALUES(1),(2),(3)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	s.UpdateSQL(2, "DELETE FROM p WHERE x > 1")
+	s.Preview(2, "SELECT COUNT(*) FROM p WHERE x > 1")
+	est, er := s.PreviewImpact(db)
+	if er != nil || len(est) != 1 || est[0].Rows != 2 {
+		t.Fatal(est, er)
+	}
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import "time"
+
+// Result describes the outcome of a successful Schema.InstallResult.
+type Result struct {
+	// From is the database's version before any migrations were applied, and
+	// To the version it was stamped with afterwards.
+	From, To int
+
+	// Applied lists the migrations that were run, in the order they ran.
+	Applied []AppliedMigration
+
+	// Skipped lists the versions of pending migrations that were not run
+	// because none of their tags is in the active environment, or because
+	// their AlreadyApplied predicate reported them as present.
+	Skipped []int
+
+	// Resumed lists the versions that Schema.InstallResumable didn't run
+	// because an earlier, interrupted run had already committed them.
+	Resumed []int
+
+	// UpToDate is set if the database was already at (or past) the requested
+	// version and nothing was done, not even re-stamping the version.
+	UpToDate bool
+
+	// committed is the number of leading Applied entries whose transaction
+	// has been committed.
+	committed int
+
+	// target is the version being migrated to, for Hooks.
+	target int
+}
+
+// AppliedMigration describes a single migration run by Schema.InstallResult.
+type AppliedMigration struct {
+	// Version is the migration's minVersion, and Name and Description its
+	// name and description, if it has them (see Schema.UpdateNamed).
+	Version     int
+	Name        string
+	Description string
+
+	// RowsAffected is the row count returned by a closure registered with
+	// Schema.UpdateRows, or -1 for closures that don't report one.
+	RowsAffected int64
+
+	// Duration is how long the closure took to run.
+	Duration time.Duration
+
+	// Checksum is the migration's Migration.Hash, as recorded in the history
+	// table.
+	Checksum string
+}
@@ -0,0 +1,194 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// InstallResumable is like Install using TxSingle, but each group of
+// migrations sharing a minVersion runs inside its own savepoint, and the
+// history table (see WithHistory, which InstallResumable implies) records each
+// group as it completes. If a migration fails, the transaction is rolled back
+// to the failing group's savepoint only, and the groups that completed are
+// committed without advancing the version stamp; the MigrationError is then
+// returned. The next InstallResumable skips the groups whose history rows show
+// them as already committed and carries on from the one that failed. Plain
+// Install doesn't know about these partial runs, so a database left in such a
+// state must be finished with InstallResumable.
+//
+// This relies on transactional DDL and savepoints, as on Postgres.
+func (s *Schema) InstallResumable(db *sql.DB, maxVersion int) error {
+	resumable := s.forDB(db).clone()
+	resumable.history = true
+	return resumable.installResumable(db, maxVersion)
+}
+
+func (s *Schema) installResumable(db *sql.DB, maxVersion int) error {
+	ctx := context.Background()
+	res := &Result{target: maxVersion}
+
+	migrations, er := s.collect()
+	if er != nil {
+		return er
+	}
+
+	if er := s.checkMaxVersion(migrations, maxVersion); er != nil {
+		return er
+	}
+
+	var failed *MigrationError
+	er = s.session(ctx, db, func(conn DB) error {
+		if er := s.preflight(ctx, conn, migrations); er != nil {
+			return er
+		}
+
+		return s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			er := s.applyResumable(ctx, tx, migrations, version, maxVersion, res)
+			if errors.As(er, &failed) {
+				// Rolled back to the failing group's savepoint; commit the
+				// groups before it.
+				return nil
+			}
+
+			return er
+		})
+	})
+	if er == nil {
+		res.committed = len(res.Applied)
+
+		if failed != nil {
+			er = failed
+		}
+	}
+
+	if auditEr := s.audit(ctx, res, er); er == nil {
+		er = auditEr
+	}
+	if er != nil {
+		return er
+	}
+
+	return s.runAfterCommit(db)
+}
+
+// applyResumable is the InstallResumable counterpart of apply.
+func (s *Schema) applyResumable(ctx context.Context, tx *sql.Tx, migrations []migration, version, maxVersion int, res *Result) error {
+	done, er := s.historyVersions(ctx, s.executor(tx), version)
+	if er != nil {
+		return er
+	}
+
+	res.Applied, res.Skipped, res.Resumed = nil, nil, nil
+	res.From = version
+	from := version
+	ran := make(map[int]bool)
+
+	var groups [][]migration
+	if version == 0 && s.checkpoint != nil && s.checkpoint.minVersion <= maxVersion {
+		groups = append(groups, []migration{*s.checkpoint})
+		version = s.checkpoint.minVersion
+	}
+
+	for _, m := range migrations {
+		if !s.pending(m, version, maxVersion) {
+			continue
+		}
+
+		if n := len(groups); n > 0 && groups[n-1][0].minVersion == m.minVersion {
+			groups[n-1] = append(groups[n-1], m)
+
+		} else {
+			groups = append(groups, []migration{m})
+		}
+	}
+
+	for _, group := range groups {
+		if done[group[0].minVersion] {
+			for _, m := range group {
+				s.logMigration(m, "skipped: already committed by an interrupted run")
+			}
+
+			res.Resumed = append(res.Resumed, group[0].minVersion)
+			ran[group[0].minVersion] = true
+			continue
+		}
+
+		if er := s.checkCap(ctx, s.executor(tx), group[0].minVersion); er != nil {
+			return er
+		}
+
+		if er := s.applySavepoint(ctx, tx, from, group, ran, res); er != nil {
+			return er
+		}
+	}
+
+	to := s.stampVersion(migrations, from, maxVersion, res)
+	if len(res.Applied) == 0 && len(res.Skipped) == 0 && len(res.Resumed) == 0 && from >= to {
+		res.To = from
+		res.UpToDate = true
+		return nil
+	}
+
+	if to > from {
+		if er := s.checkCap(ctx, s.executor(tx), to); er != nil {
+			return er
+		}
+	}
+
+	if er := s.finalize(tx); er != nil {
+		return er
+	}
+
+	if er := s.setInstalledVersion(ctx, tx, migrations, from, to); er != nil {
+		return er
+	}
+
+	res.To = to
+	return nil
+}
+
+// applySavepoint runs group inside a savepoint. If one of its migrations fails,
+// the transaction is rolled back to the savepoint, and the dirty flag cleared
+// again, before the MigrationError is returned.
+func (s *Schema) applySavepoint(ctx context.Context, tx *sql.Tx, version int, group []migration, ran map[int]bool, res *Result) error {
+	const savepoint = "migrate_step"
+	dialect := s.getDialect()
+	q := s.executor(tx)
+
+	if _, er := q.ExecContext(ctx, dialect.Savepoint(savepoint)); er != nil {
+		return er
+	}
+
+	applied := len(res.Applied)
+	for _, migration := range group {
+		er := s.runMigration(ctx, tx, version, migration, ran, res)
+		if er == nil {
+			continue
+		}
+
+		var failed *MigrationError
+		if !errors.As(er, &failed) {
+			return er
+		}
+
+		if _, er := q.ExecContext(ctx, dialect.RollbackToSavepoint(savepoint)); er != nil {
+			return er
+		}
+
+		if er := s.setDirty(ctx, tx, false); er != nil {
+			return er
+		}
+
+		res.Applied = res.Applied[:applied]
+		return failed
+	}
+
+	if release := dialect.ReleaseSavepoint(savepoint); release != "" {
+		if _, er := q.ExecContext(ctx, release); er != nil {
+			return er
+		}
+	}
+
+	return nil
+}
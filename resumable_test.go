@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestResumable(t *testing.T) {
+	db := openDB(t)
+	fail := true
+	var s Schema
+	s.Update(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE ra(x INT)"); return er })
+	s.Update(2, func(v int, tx *sql.Tx) error {
+		if _, er := tx.Exec("CREATE TABLE rb(x INT)"); er != nil {
+			return er
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	s.Update(3, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE rc(x INT)"); return er })
+	var me *MigrationError
+	if er := s.InstallResumable(db, 3); !errors.As(er, &me) || me.Version != 2 {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM ra"); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM rb"); er == nil {
+		t.Fatal("rb exists")
+	}
+	fail = false
+	if er := s.InstallResumable(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 3 {
+		t.Fatal(v)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM migration_history").Scan(&n)
+	if n != 3 {
+		t.Fatal(n)
+	}
+}
+
+func TestResumableBookkeeping(t *testing.T) {
+	db := openDB(t)
+	runs := make(map[string]int)
+	step := func(name, query string, fail *bool) func(int, *sql.Tx) error {
+		return func(v int, tx *sql.Tx) error {
+			runs[name]++
+			if _, er := tx.Exec(query); er != nil {
+				return er
+			}
+			if fail != nil && *fail {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	fail := true
+	var s Schema
+	s.Update(1, step("1a", "CREATE TABLE ba(x INT)", nil))
+	s.Update(1, step("1b", "CREATE TABLE bb(x INT)", nil))
+	s.Update(2, step("2a", "CREATE TABLE bc(x INT)", nil))
+	s.Update(2, step("2b", "CREATE TABLE bd(x INT)", &fail))
+	s.Update(3, step("3", "CREATE TABLE be(x INT)", nil))
+
+	if er := s.InstallResumable(db, 3); er == nil {
+		t.Fatal("expected failure")
+	}
+	var versions []int
+	rows, er := db.Query("SELECT version FROM migration_history ORDER BY version")
+	if er != nil {
+		t.Fatal(er)
+	}
+	for rows.Next() {
+		var v int
+		rows.Scan(&v)
+		versions = append(versions, v)
+	}
+	rows.Close()
+	// Group 1 is committed (one row per migration); group 2 is rolled back to
+	// its savepoint as a whole.
+	if len(versions) != 2 || versions[0] != 1 || versions[1] != 1 {
+		t.Fatal(versions)
+	}
+	if _, er := db.Exec("SELECT * FROM bc"); er == nil {
+		t.Fatal("bc survived the savepoint rollback")
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+
+	fail = false
+	if er := s.InstallResumable(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	if runs["1a"] != 1 || runs["1b"] != 1 || runs["2a"] != 2 || runs["2b"] != 2 || runs["3"] != 1 {
+		t.Fatal(runs)
+	}
+	if v, _ := Version(db); v != 3 {
+		t.Fatal(v)
+	}
+	if er := s.InstallResumable(db, 3); er != nil || runs["3"] != 1 {
+		t.Fatal(er, runs)
+	}
+}
@@ -0,0 +1,240 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// ErrNoDown is returned by Schema.Rollback when a migration that would have to
+// be reverted has no down closure registered.
+var ErrNoDown = errors.New("migrate: migration has no down closure")
+
+type downMigration struct {
+	down       func(context.Context, *Schema, int, *sql.Tx) error
+	bestEffort bool
+}
+
+// Down registers the closure that reverts the migrations registered with the
+// same minVersion via Schema.Update. Down closures are only run by
+// Schema.Rollback. The closure is passed the database's current version and
+// the transaction in which to perform the rollback.
+func (s *Schema) Down(minVersion int, f func(int, *sql.Tx) error) {
+	s.setDown(minVersion, downMigration{down: ignoreContext(f)})
+}
+
+// DownContext is like Down, but the closure is also passed the context of the
+// rollback, as for Schema.UpdateContext.
+func (s *Schema) DownContext(minVersion int, f func(context.Context, int, *sql.Tx) error) {
+	s.setDown(minVersion, downMigration{
+		down: func(ctx context.Context, _ *Schema, version int, tx *sql.Tx) error {
+			return f(ctx, version, tx)
+		},
+	})
+}
+
+// UpdateReversible registers up as a migration, exactly as Update does, and
+// down as the closure that reverts it, exactly as Down does, keeping the two
+// halves of a migration side by side.
+func (s *Schema) UpdateReversible(minVersion int, up, down func(int, *sql.Tx) error) {
+	s.Update(minVersion, up)
+	s.Down(minVersion, down)
+}
+
+// DownBestEffort is like Down, but if the closure fails because an object it
+// references does not exist (e.g. dropping a column that the up migration never
+// got as far as creating) the error is ignored and the rollback continues. The
+// closure runs inside a savepoint so the transaction remains usable; note that
+// any statements following the failing one in the same closure are skipped.
+// Other errors still abort the rollback.
+func (s *Schema) DownBestEffort(minVersion int, f func(int, *sql.Tx) error) {
+	s.setDown(minVersion, downMigration{down: ignoreContext(f), bestEffort: true})
+}
+
+func ignoreContext(f func(int, *sql.Tx) error) func(context.Context, *Schema, int, *sql.Tx) error {
+	return func(_ context.Context, _ *Schema, version int, tx *sql.Tx) error {
+		return f(version, tx)
+	}
+}
+
+func (s *Schema) setDown(minVersion int, d downMigration) {
+	if s.downs == nil {
+		s.downs = make(map[int]downMigration)
+	}
+
+	s.downs[minVersion] = d
+}
+
+// Rollback reverts the database to targetVersion. The down closures of every
+// registered minVersion greater than targetVersion and no greater than the
+// database's current version are run from newest to oldest, after which the
+// database is stamped with targetVersion. Everything happens in one
+// transaction. If any of those versions has no down closure, ErrNoDown is
+// returned before anything runs. As with DownOne, the history rows of the
+// reverted versions (see WithHistory) are kept but marked reverted.
+func (s *Schema) Rollback(db *sql.DB, targetVersion int) error {
+	s = s.forDB(db)
+	if targetVersion < 0 {
+		return fmt.Errorf("migrate: invalid rollback target %d", targetVersion)
+	}
+
+	ctx := context.Background()
+
+	return s.session(ctx, db, func(conn DB) error {
+		if er := s.checkClean(ctx, conn); er != nil {
+			return er
+		}
+
+		return s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			return s.rollback(ctx, tx, version, targetVersion)
+		})
+	})
+}
+
+func (s *Schema) rollback(ctx context.Context, tx *sql.Tx, version, targetVersion int) error {
+	if targetVersion >= version {
+		return nil
+	}
+
+	versions := s.versionsBetween(targetVersion, version)
+	for _, v := range versions {
+		if _, ok := s.downs[v]; !ok {
+			return fmt.Errorf("%w: version %d", ErrNoDown, v)
+		}
+	}
+
+	if er := s.setDirty(ctx, tx, true); er != nil {
+		return er
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		s.sqlLog.setVersion(versions[i])
+		er := s.runDown(ctx, tx, version, s.downs[versions[i]])
+		s.sqlLog.setVersion(0)
+		if er != nil {
+			return er
+		}
+
+		if er := s.recordEvent(ctx, tx, versions[i], "down", s.migrationName(versions[i])); er != nil {
+			return er
+		}
+	}
+
+	if er := s.revertHistory(ctx, tx, targetVersion); er != nil {
+		return er
+	}
+
+	return s.setDbVersion(ctx, tx, version, targetVersion)
+}
+
+// DownOne reverts just the migration registered with version, leaving the
+// migrations after it in place, for use when they don't depend on it. Its
+// down closure is run in a transaction, returning ErrNoDown if it has none;
+// version must not be above the database's version. The version stamp is left
+// alone, so the database's bookkeeping no longer describes it linearly:
+// Install will not re-apply the reverted migration, and Rollback past it will
+// run its down closure again. Its history rows (see WithHistory) are kept but
+// marked reverted, so that WithOutOfOrder doesn't take it for a missed
+// migration, and with WithEvents a "down" event is recorded. A warning is
+// logged.
+func (s *Schema) DownOne(db *sql.DB, version int) error {
+	s = s.forDB(db)
+	d, ok := s.downs[version]
+	if !ok {
+		return fmt.Errorf("%w: version %d", ErrNoDown, version)
+	}
+
+	ctx := context.Background()
+
+	return s.session(ctx, db, func(conn DB) error {
+		if er := s.checkClean(ctx, conn); er != nil {
+			return er
+		}
+
+		return s.transact(ctx, conn, func(tx *sql.Tx, current int) error {
+			if version > current {
+				return fmt.Errorf("migrate: can't revert migration %d of a database at version %d", version, current)
+			}
+
+			log.Printf("migrate: WARNING: reverting migration %d of a database at version %d; the version stamp no longer reflects the schema", version, current)
+
+			s.sqlLog.setVersion(version)
+			er := s.runDown(ctx, tx, current, d)
+			s.sqlLog.setVersion(0)
+			if er != nil {
+				return er
+			}
+
+			if s.history {
+				d := s.getDialect()
+				if _, er := s.executor(tx).ExecContext(ctx, "UPDATE "+s.historyTable()+" SET status = "+d.Placeholder(1)+" WHERE version = "+d.Placeholder(2), historyReverted, version); er != nil {
+					return er
+				}
+			}
+
+			return s.recordEvent(ctx, tx, version, "down", s.migrationName(version))
+		})
+	})
+}
+
+// migrationName returns the name of the first migration registered with
+// minVersion, or "".
+func (s *Schema) migrationName(minVersion int) string {
+	for _, m := range s.migrations {
+		if m.minVersion == minVersion {
+			return m.name
+		}
+	}
+
+	return ""
+}
+
+// versionsBetween returns the distinct registered minVersions v with
+// lo < v <= hi, in ascending order.
+func (s *Schema) versionsBetween(lo, hi int) []int {
+	seen := make(map[int]bool)
+	var versions []int
+
+	for _, m := range s.migrations {
+		if m.minVersion > lo && m.minVersion <= hi && !seen[m.minVersion] {
+			seen[m.minVersion] = true
+			versions = append(versions, m.minVersion)
+		}
+	}
+
+	sort.Ints(versions)
+	return versions
+}
+
+func (s *Schema) runDown(ctx context.Context, tx *sql.Tx, version int, d downMigration) error {
+	if !d.bestEffort {
+		return d.down(ctx, s, version, tx)
+	}
+
+	const savepoint = "migrate_best_effort"
+	dialect := s.getDialect()
+
+	if _, er := tx.ExecContext(ctx, dialect.Savepoint(savepoint)); er != nil {
+		return er
+	}
+
+	if er := d.down(ctx, s, version, tx); er != nil {
+		if !IsUndefinedObject(er) {
+			return er
+		}
+
+		_, er = tx.ExecContext(ctx, dialect.RollbackToSavepoint(savepoint))
+		return er
+	}
+
+	if q := dialect.ReleaseSavepoint(savepoint); q != "" {
+		if _, er := tx.ExecContext(ctx, q); er != nil {
+			return er
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Update(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE a(x INT)"); return er })
+	s.Down(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("DROP TABLE a"); return er })
+	s.Update(2, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE b(x INT)"); return er })
+	s.DownBestEffort(2, func(v int, tx *sql.Tx) error { _, er := tx.Exec("DROP TABLE nope"); return er })
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Rollback(db, 0); er != nil {
+		t.Fatal(er)
+	}
+	var v int
+	db.QueryRow("SELECT version FROM version").Scan(&v)
+	if v != 0 {
+		t.Fatal(v)
+	}
+	if _, er := db.Exec("SELECT * FROM a"); er == nil {
+		t.Fatal("a exists")
+	}
+}
+
+func TestDownOne(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory(), WithEvents())
+	s.UpdateSQL(1, "CREATE TABLE d1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE d2(x INT)")
+	s.Down(1, func(v int, tx *sql.Tx) error { _, er := tx.Exec("DROP TABLE d1"); return er })
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.DownOne(db, 2); !errors.Is(er, ErrNoDown) {
+		t.Fatal(er)
+	}
+	if er := s.DownOne(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM d1"); er == nil {
+		t.Fatal("d1 exists")
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestDownOneOutOfOrder(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithOutOfOrder(OutOfOrderApply))
+	s.UpdateSQL(1, "CREATE TABLE o1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE o2(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE o3(x INT)")
+	s.Down(2, func(v int, tx *sql.Tx) error { _, er := tx.Exec("DROP TABLE o2"); return er })
+	if er := s.Install(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.DownOne(db, 2); er != nil {
+		t.Fatal(er)
+	}
+
+	s.UpdateSQL(4, "CREATE TABLE o4(x INT)")
+	if er := s.Install(db, 4); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM o2"); er == nil {
+		t.Fatal("reverted migration re-applied")
+	}
+
+	s.Configure(WithOutOfOrder(OutOfOrderFail))
+	if er := s.Install(db, 4); er != nil {
+		t.Fatal(er)
+	}
+
+	st, er := s.Status(db)
+	if er != nil || len(st) != 4 || st[1].Applied || !st[1].Reverted || !st[0].Applied || st[0].Reverted {
+		t.Fatal(st, er)
+	}
+	out, er := s.StatusTable(db)
+	if er != nil || !strings.Contains(out, "reverted") {
+		t.Fatal(out, er)
+	}
+}
+
+func TestRollbackHistory(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.UpdateSQL(1, "CREATE TABLE rh1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE rh2(x INT)")
+	s.Down(2, func(v int, tx *sql.Tx) error { _, er := tx.Exec("DROP TABLE rh2"); return er })
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Rollback(db, 1); er != nil {
+		t.Fatal(er)
+	}
+
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM migration_history WHERE version = 2 AND status = 'reverted'").Scan(&n)
+	if n != 1 {
+		t.Fatal(n)
+	}
+	st, er := s.Status(db)
+	if er != nil || len(st) != 2 || st[1].Applied || !st[1].Reverted || st[0].Reverted {
+		t.Fatal(st, er)
+	}
+
+	var removed Schema
+	removed.Configure(WithHistory())
+	removed.UpdateSQL(1, "CREATE TABLE rh1(x INT)")
+	if er := removed.ValidateDB(db); er != nil {
+		t.Fatal(er)
+	}
+
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM rh2"); er != nil {
+		t.Fatal(er)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM migration_history WHERE version = 2").Scan(&n)
+	if n != 2 {
+		t.Fatal(n)
+	}
+	st, er = s.Status(db)
+	if er != nil || !st[1].Applied || st[1].Reverted {
+		t.Fatal(st, er)
+	}
+	if er := removed.ValidateDB(db); er == nil {
+		t.Fatal("expected re-applied version 2 to be orphaned")
+	}
+}
+
+func TestUpdateReversible(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateReversible(1,
+		func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE rv(x INT)"); return er },
+		func(v int, tx *sql.Tx) error { _, er := tx.Exec("DROP TABLE rv"); return er },
+	)
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM rv"); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Rollback(db, 0); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM rv"); er == nil {
+		t.Fatal("rv exists")
+	}
+	if v, _ := Version(db); v != 0 {
+		t.Fatal(v)
+	}
+}
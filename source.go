@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+)
+
+// Migration describes a migration supplied by a source registered with
+// Schema.AddSource, or one reported by Schema.Migrations.
+type Migration struct {
+	// Version is the migration's minVersion.
+	Version int
+
+	// Name is a short human-readable identifier for the migration, and
+	// Description an optional longer account of what it does. The
+	// description isn't part of the Hash.
+	Name        string
+	Description string
+
+	// SQL is executed within the migration transaction, exactly as for
+	// Schema.UpdateSQL. Schema.Migrations leaves it empty for migrations
+	// registered as closures.
+	SQL string
+}
+
+// Hash returns a stable hex-encoded SHA-256 digest of the migration's version,
+// name and SQL, suitable as a cache key. Closures can't be hashed, so for
+// those it only reflects the version and name.
+func (m Migration) Hash() string {
+	h := migrationHash(m.Version, m.Name)
+	h.Write([]byte(m.SQL))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// migrationHash returns the hash that Migration.Hash computes, primed with
+// version and name, so that the SQL can be written to it piecemeal.
+func migrationHash(version int, name string) hash.Hash {
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(version) + "\x00" + name + "\x00"))
+	return h
+}
+
+// Migrations returns every migration Install would consider, in the order it
+// would consider them, including those fetched from sources (which are
+// fetched anew).
+func (s *Schema) Migrations() ([]Migration, error) {
+	migrations, er := s.collect()
+	if er != nil {
+		return nil, er
+	}
+
+	described := make([]Migration, len(migrations))
+	for i, m := range migrations {
+		described[i] = Migration{Version: m.minVersion, Name: m.name, Description: m.description, SQL: m.sql}
+	}
+
+	return described, nil
+}
+
+// AddSource registers a function that is invoked by every Install to fetch
+// additional migrations, e.g. those defined by installed plugins. The fetched
+// migrations are merged into the registered ones in version order, and are
+// then applied like any other migration. A fetched migration whose Version
+// collides with any other migration, registered or fetched, is an error.
+func (s *Schema) AddSource(f func() ([]Migration, error)) {
+	s.sources = append(s.sources, f)
+}
+
+// collect returns the registered migrations merged with those fetched from the
+// configured sources.
+func (s *Schema) collect() ([]migration, error) {
+	if len(s.sources) == 0 {
+		return s.migrations, nil
+	}
+
+	versions := make(map[int]bool)
+	for _, m := range s.migrations {
+		versions[m.minVersion] = true
+	}
+
+	migrations := append([]migration(nil), s.migrations...)
+
+	for _, source := range s.sources {
+		fetched, er := source()
+		if er != nil {
+			return nil, fmt.Errorf("migrate: fetching migrations: %w", er)
+		}
+
+		for _, m := range fetched {
+			if versions[m.Version] {
+				return nil, fmt.Errorf("migrate: sourced migration %d (%s) duplicates an existing version", m.Version, m.Name)
+			}
+			versions[m.Version] = true
+
+			migrations = insertMigration(migrations, migration{
+				minVersion:  m.Version,
+				name:        m.Name,
+				description: m.Description,
+				sql:         m.SQL,
+				up:          execStatements(m.SQL),
+			})
+		}
+	}
+
+	return migrations, nil
+}
+
+// insertMigration inserts m after the last element of migrations whose
+// minVersion is no greater than m's.
+func insertMigration(migrations []migration, m migration) []migration {
+	i := len(migrations)
+	for i > 0 && migrations[i-1].minVersion > m.minVersion {
+		i--
+	}
+
+	migrations = append(migrations, migration{})
+	copy(migrations[i+1:], migrations[i:])
+	migrations[i] = m
+	return migrations
+}
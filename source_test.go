@@ -0,0 +1,29 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddSource(t *testing.T) {
+	db := openDB(t)
+	var log strings.Builder
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithIdempotentDDL(), WithSQLLog(&log))
+	db.Exec("CREATE TABLE src(x INT)")
+	s.AddSource(func() ([]Migration, error) {
+		return []Migration{{Version: 1, Name: "src", SQL: "CREATE TABLE src(x INT); INSERT INTO src VALUES(1)"}}, nil
+	})
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if out := log.String(); !strings.Contains(out, "1: CREATE TABLE IF NOT EXISTS src") || !strings.Contains(out, "1: INSERT INTO src") {
+		t.Fatal(out)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM src").Scan(&n)
+	if n != 1 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WithSQLLog writes every statement run through the migration transaction
+// (including migrate's own bookkeeping) to w, one per line, prefixed with the
+// version of the migration running it, or with "migrate" for bookkeeping. Like
+// WithTxWrapper it covers SQL migrations and closures registered with
+// Schema.UpdateExec, but not closures taking a raw *sql.Tx. w is only written
+// to by the goroutine migrating, but a Schema with WithSQLLog mustn't install
+// into several databases concurrently.
+func WithSQLLog(w io.Writer) Option {
+	return func(s *Schema) {
+		s.sqlLog = &sqlLog{w: w}
+	}
+}
+
+type sqlLog struct {
+	w io.Writer
+
+	// version is the migration currently running, or 0 between migrations.
+	version int
+}
+
+// setVersion records the migration whose statements are about to run. It does
+// nothing if l is nil.
+func (l *sqlLog) setVersion(version int) {
+	if l != nil {
+		l.version = version
+	}
+}
+
+func (l *sqlLog) write(query string) {
+	prefix := "migrate"
+	if l.version != 0 {
+		prefix = fmt.Sprint(l.version)
+	}
+
+	fmt.Fprintf(l.w, "%s: %s\n", prefix, strings.Join(strings.Fields(query), " "))
+}
+
+type sqlLogExecutor struct {
+	QueryExecutor
+	log *sqlLog
+}
+
+func (e *sqlLogExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	e.log.write(query)
+	return e.QueryExecutor.Exec(query, args...)
+}
+
+func (e *sqlLogExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.log.write(query)
+	return e.QueryExecutor.ExecContext(ctx, query, args...)
+}
+
+func (e *sqlLogExecutor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	e.log.write(query)
+	return e.QueryExecutor.Query(query, args...)
+}
+
+func (e *sqlLogExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	e.log.write(query)
+	return e.QueryExecutor.QueryContext(ctx, query, args...)
+}
+
+func (e *sqlLogExecutor) QueryRow(query string, args ...interface{}) *sql.Row {
+	e.log.write(query)
+	return e.QueryExecutor.QueryRow(query, args...)
+}
+
+func (e *sqlLogExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	e.log.write(query)
+	return e.QueryExecutor.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,20 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSQLLog(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	var b strings.Builder
+	s.Configure(WithSQLLog(&b))
+	s.UpdateSQL(1, "CREATE TABLE a(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if !strings.Contains(b.String(), "1: CREATE TABLE a(x INT)\n") || !strings.Contains(b.String(), "migrate: ") {
+		t.Fatal(b.String())
+	}
+}
@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// MigrationStatus describes a registered migration as reported by
+// Schema.Status.
+type MigrationStatus struct {
+	Version     int
+	Name        string
+	Description string
+
+	// Applied is set if the migration's minVersion is no greater than the
+	// database's version, and it was neither skipped nor reverted.
+	Applied bool
+
+	// Skipped is set if the history table records that the migration was
+	// skipped for its tags (see Schema.Tag), and Reverted if it was reverted
+	// with Schema.DownOne or Schema.Rollback and not applied again since.
+	Skipped  bool
+	Reverted bool
+
+	// AppliedAt is when the migration was first applied, according to the
+	// history table (see WithHistory). It is the zero time if there is no
+	// history table or no row for the migration, or if the driver returned
+	// applied_at in a form that can't be parsed.
+	AppliedAt time.Time
+}
+
+// Status reports every registered migration, in the order Install would
+// consider them, together with whether it has been applied to db, e.g. for an
+// admin or health endpoint. Like Plan it only reads from db.
+func (s *Schema) Status(db *sql.DB) ([]MigrationStatus, error) {
+	s = s.forDB(db)
+	migrations, er := s.collect()
+	if er != nil {
+		return nil, er
+	}
+
+	plan, er := s.Plan(db, s.latestVersion(migrations))
+	if er != nil {
+		return nil, er
+	}
+
+	history, er := s.versionHistory(context.Background(), db)
+	if er != nil {
+		return nil, er
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		h := history[m.minVersion]
+		statuses[i] = MigrationStatus{
+			Version:     m.minVersion,
+			Name:        m.name,
+			Description: m.description,
+			Applied:     m.minVersion <= plan.Current && !h.skipped && !h.reverted,
+			Skipped:     h.skipped,
+			Reverted:    h.reverted,
+			AppliedAt:   h.appliedAt,
+		}
+	}
+
+	return statuses, nil
+}
+
+// StatusTable renders Status as a column-aligned text table for operators,
+// one row per migration:
+//
+//	VERSION  NAME       STATUS   APPLIED AT
+//	1        users      applied  2024-01-15 09:30:00
+//	2        add_email  pending
+//
+// STATUS is applied, pending, skipped or reverted, and APPLIED AT is left blank
+// where Status has no time.
+func (s *Schema) StatusTable(db *sql.DB) (string, error) {
+	statuses, er := s.Status(db)
+	if er != nil {
+		return "", er
+	}
+
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	for _, m := range statuses {
+		status := "pending"
+		switch {
+		case m.Applied:
+			status = "applied"
+
+		case m.Skipped:
+			status = "skipped"
+
+		case m.Reverted:
+			status = "reverted"
+		}
+
+		var appliedAt string
+		if !m.AppliedAt.IsZero() {
+			appliedAt = m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.Version, m.Name, status, appliedAt)
+	}
+
+	if er := w.Flush(); er != nil {
+		return "", er
+	}
+
+	return out.String(), nil
+}
+
+// versionHistory describes a version's rows in the history table.
+type versionHistory struct {
+	// appliedAt is when the version was first applied.
+	appliedAt time.Time
+	skipped   bool
+	reverted  bool
+}
+
+// versionHistory returns what the history table says about each version, or
+// nil if there is no history table.
+func (s *Schema) versionHistory(ctx context.Context, db *sql.DB) (map[int]versionHistory, error) {
+	if er := s.checkHistoryTable(); er != nil {
+		return nil, er
+	}
+
+	exists, er := s.getDialect().TableExists(ctx, db, s.historyTable())
+	if er != nil || !exists {
+		return nil, er
+	}
+
+	// A table created by an older version of migrate may lack the status
+	// column until the next Install adds it.
+	status := "NULL"
+	if hasColumn(ctx, db, s.historyTable(), "status") {
+		status = "status"
+	}
+
+	rows, er := db.QueryContext(ctx, "SELECT version, applied_at, "+status+" FROM "+s.historyTable())
+	if er != nil {
+		return nil, er
+	}
+	defer rows.Close()
+
+	history := make(map[int]versionHistory)
+	live := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		var appliedAt interface{}
+		var status sql.NullString
+
+		if er := rows.Scan(&version, &appliedAt, &status); er != nil {
+			return nil, er
+		}
+
+		h, seen := history[version]
+		if t := parseTime(appliedAt); !seen || t.Before(h.appliedAt) {
+			h.appliedAt = t
+		}
+
+		h.skipped = h.skipped || status.String == historySkipped
+		h.reverted = h.reverted || status.String == historyReverted
+		live[version] = live[version] || status.String != historyReverted
+		history[version] = h
+	}
+
+	// A version that was reverted and then applied again isn't reverted.
+	for version, h := range history {
+		if live[version] {
+			h.reverted = false
+			history[version] = h
+		}
+	}
+
+	return history, rows.Err()
+}
+
+// timeLayouts are the layouts parseTime tries on timestamps that drivers
+// return as text.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseTime converts a timestamp scanned from the history table to a time, or
+// the zero time if it isn't in a recognised form.
+func parseTime(value interface{}) time.Time {
+	var text string
+	switch t := value.(type) {
+	case time.Time:
+		return t
+
+	case []byte:
+		text = string(t)
+
+	case string:
+		text = t
+
+	default:
+		return time.Time{}
+	}
+
+	for _, layout := range timeLayouts {
+		if t, er := time.Parse(layout, text); er == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,38 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestStatusTable(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.UpdateNamed(1, "users", "", func(v int, tx *sql.Tx) error { return nil })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	s.UpdateNamed(2, "add_email", "", func(v int, tx *sql.Tx) error { return nil })
+	out, er := s.StatusTable(db)
+	if er != nil || !strings.Contains(out, "pending") || !strings.Contains(out, "applied") {
+		t.Fatal(out, er)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithHistory())
+	s.UpdateNamed(1, "one", "", func(int, *sql.Tx) error { return nil })
+	s.UpdateNamed(2, "two", "", func(int, *sql.Tx) error { return nil })
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	st, er := s.Status(db)
+	if er != nil || len(st) != 2 || !st[0].Applied || st[0].AppliedAt.IsZero() || st[1].Applied || st[0].Name != "one" {
+		t.Fatal(er, st)
+	}
+}
@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Step applies only the next pending migration, i.e. every migration registered
+// with the lowest minVersion above the database's version, in its own
+// transaction along with a stamp of that minVersion. It reports the version
+// it applied and whether any migrations remain pending afterwards; if none
+// were pending to begin with it returns 0 and true. Calling Step until it
+// reports done brings the database to the latest version, with each step
+// committed before the next begins. AfterCommit hooks run after the step that
+// completes the chain.
+func (s *Schema) Step(db *sql.DB) (appliedVersion int, done bool, er error) {
+	s = s.forDB(db)
+	ctx := context.Background()
+	res := &Result{}
+
+	migrations, er := s.collect()
+	if er != nil {
+		return 0, false, er
+	}
+
+	er = s.session(ctx, db, func(conn DB) error {
+		if er := s.preflight(ctx, conn, migrations); er != nil {
+			return er
+		}
+
+		er := s.transact(ctx, conn, func(tx *sql.Tx, version int) error {
+			res.Applied, res.Skipped = nil, nil
+			res.From = version
+			ran := make(map[int]bool)
+
+			var next []migration
+			if version == 0 && s.checkpoint != nil {
+				next = []migration{*s.checkpoint}
+
+			} else {
+				for _, m := range migrations {
+					if m.minVersion <= version {
+						continue
+					}
+
+					if len(next) > 0 && m.minVersion > next[0].minVersion {
+						continue
+					}
+
+					if len(next) > 0 && m.minVersion < next[0].minVersion {
+						next = next[:0]
+					}
+
+					next = append(next, m)
+				}
+			}
+
+			if len(next) == 0 {
+				res.To = version
+				res.UpToDate = true
+				return nil
+			}
+
+			if er := s.checkCap(ctx, s.executor(tx), next[0].minVersion); er != nil {
+				return er
+			}
+
+			for _, m := range next {
+				if er := s.runMigration(ctx, tx, version, m, ran, res); er != nil {
+					return er
+				}
+			}
+
+			res.To = next[0].minVersion
+			return s.setInstalledVersion(ctx, tx, migrations, version, res.To)
+		})
+		if er != nil {
+			return er
+		}
+
+		res.committed = len(res.Applied)
+		return nil
+	})
+	if auditEr := s.audit(ctx, res, er); er == nil {
+		er = auditEr
+	}
+	if er != nil {
+		return 0, false, er
+	}
+
+	if res.UpToDate {
+		return 0, true, nil
+	}
+
+	done = res.To >= s.latestVersion(migrations)
+	if done {
+		er = s.runAfterCommit(db)
+	}
+
+	return res.To, done, er
+}
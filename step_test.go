@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestInstallStepwise(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithTxMode(TxSingle))
+	s.UpdateSQL(1, "CREATE TABLE s1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE nope nope")
+	if _, er := s.InstallStepwise(db, 2); er == nil {
+		t.Fatal("expected failure")
+	}
+	if v, _ := Version(db); v != 1 {
+		t.Fatal(v)
+	}
+}
+
+func TestStep(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithDirtyFlag())
+	s.UpdateSQL(1, "CREATE TABLE st1(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE st3(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE st3b(x INT)")
+	for _, want := range []int{1, 3} {
+		v, done, er := s.Step(db)
+		if er != nil || v != want || done != (want == 3) {
+			t.Fatal(v, done, er)
+		}
+		if got, _ := Version(db); got != want {
+			t.Fatal(got)
+		}
+	}
+	if _, er := db.Exec("SELECT * FROM st3b"); er != nil {
+		t.Fatal(er)
+	}
+	if v, done, er := s.Step(db); er != nil || v != 0 || !done {
+		t.Fatal(v, done, er)
+	}
+
+	s.UpdateSQL(4, "CREATE TABLE st4(x INT)")
+	db.Exec("UPDATE version SET dirty = 1")
+	if _, _, er := s.Step(db); !errors.Is(er, ErrDirtyDatabase) {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM st4"); er == nil {
+		t.Fatal("st4 exists")
+	}
+}
+
+func TestStepPreflight(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE sp1(x INT)")
+	s.Seed(1, nil, func(int, *sql.Tx) error { return nil })
+	if _, _, er := s.Step(db); er == nil {
+		t.Fatal("expected a seed sharing its version to be rejected")
+	}
+	if _, er := db.Exec("SELECT * FROM sp1"); er == nil {
+		t.Fatal("sp1 exists")
+	}
+}
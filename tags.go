@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Tag attaches tags to the migrations registered with minVersion, restricting
+// them to particular environments. The rule is deliberately simple:
+//
+//   - a migration without tags always runs;
+//   - a tagged migration runs only if at least one of its tags was passed to
+//     WithEnvironment; in particular, it never runs if no environment is set.
+//
+// A pending migration that doesn't run is skipped rather than deferred: it is
+// reported in Result.Skipped, recorded in the history table (see WithHistory)
+// with status "skipped", and the version stamp advances past it as usual, so
+// it will not run later either.
+func (s *Schema) Tag(minVersion int, tags ...string) {
+	if s.tags == nil {
+		s.tags = make(map[int][]string)
+	}
+
+	s.tags[minVersion] = append(s.tags[minVersion], tags...)
+}
+
+// WithEnvironment sets the tags of the environment being migrated (e.g. "dev"
+// or "prod"); see Schema.Tag.
+func WithEnvironment(tags ...string) Option {
+	return func(s *Schema) {
+		s.environment = make(map[string]bool)
+		for _, tag := range tags {
+			s.environment[tag] = true
+		}
+	}
+}
+
+// tagsActive reports whether the migrations registered with minVersion should
+// run in the configured environment.
+func (s *Schema) tagsActive(minVersion int) bool {
+	tags := s.tags[minVersion]
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, tag := range tags {
+		if s.environment[tag] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Seed registers a data migration, such as loading reference data or the
+// fixtures of a development environment, that runs like one registered with
+// Update but only in the environments named by tags, as if Tag had been called
+// with them; without tags it runs everywhere. Seeds are versioned alongside
+// the schema migrations, but a seed may not share its minVersion with a
+// schema migration, as that would restrict the schema migration to the seed's
+// environments too; Validate and Install reject such a registration. Plan
+// reports seeds with PlannedMigration.Seed set.
+func (s *Schema) Seed(minVersion int, tags []string, f func(int, *sql.Tx) error) {
+	s.Update(minVersion, f)
+	s.migrations[len(s.migrations)-1].seed = true
+
+	if len(tags) > 0 {
+		s.Tag(minVersion, tags...)
+	}
+}
+
+// validateSeeds checks that no seed shares its minVersion with a schema
+// migration.
+func validateSeeds(migrations []migration) error {
+	seed := make(map[int]bool)
+	schema := make(map[int]bool)
+
+	for _, m := range migrations {
+		if m.seed {
+			seed[m.minVersion] = true
+
+		} else {
+			schema[m.minVersion] = true
+		}
+	}
+
+	for version := range seed {
+		if schema[version] {
+			return fmt.Errorf("migrate: seed %d shares its minVersion with a schema migration", version)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.SetDialect(DialectSQLite)
+	s.Configure(WithEnvironment("prod"))
+	s.UpdateSQL(1, "CREATE TABLE sd(x INT)")
+	s.Seed(2, []string{"dev"}, func(_ int, tx *sql.Tx) error { _, er := tx.Exec("INSERT INTO sd VALUES(1)"); return er })
+	s.Seed(3, nil, func(_ int, tx *sql.Tx) error { _, er := tx.Exec("INSERT INTO sd VALUES(2)"); return er })
+	plan, _ := s.Plan(db, 3)
+	if !plan.Migrations[1].Seed || !plan.Migrations[1].Skipped {
+		t.Fatal(plan)
+	}
+	if er := s.Install(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	var n int
+	db.QueryRow("SELECT SUM(x) FROM sd").Scan(&n)
+	if n != 2 {
+		t.Fatal(n)
+	}
+	s.UpdateSQL(3, "SELECT 1")
+	if er := s.Validate(); er == nil {
+		t.Fatal("expected shared version error")
+	}
+}
+
+func TestTagSkippedHistory(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory(), WithEnvironment("prod"))
+	s.UpdateNamed(1, "base", "", func(int, *sql.Tx) error { return nil })
+	s.UpdateNamed(2, "fixtures", "", func(int, *sql.Tx) error { t.Fatal("ran"); return nil })
+	s.Tag(2, "dev")
+	res, er := s.InstallResult(db, 2)
+	if er != nil || len(res.Skipped) != 1 || res.Skipped[0] != 2 || res.To != 2 {
+		t.Fatal(res, er)
+	}
+
+	var name string
+	var status sql.NullString
+	var duration sql.NullInt64
+	if er := db.QueryRow("SELECT name, status, duration_ms FROM migration_history WHERE version = 2").Scan(&name, &status, &duration); er != nil {
+		t.Fatal(er)
+	}
+	if name != "fixtures" || status.String != "skipped" || duration.Valid {
+		t.Fatal(name, status, duration)
+	}
+	if er := db.QueryRow("SELECT status FROM migration_history WHERE version = 1").Scan(&status); er != nil || status.Valid {
+		t.Fatal(status, er)
+	}
+
+	st, er := s.Status(db)
+	if er != nil || !st[0].Applied || st[1].Applied || !st[1].Skipped {
+		t.Fatal(st, er)
+	}
+}
+
+func TestTagSkippedHistoryNoTx(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory(), WithEnvironment("prod"))
+	s.UpdateSQL(1, "CREATE TABLE tn1(x INT)")
+	s.UpdateNoTx(2, func(int, DB) error { t.Fatal("ran"); return nil })
+	s.Tag(2, "dev")
+	res, er := s.InstallResult(db, 2)
+	if er != nil || len(res.Skipped) != 1 || res.Skipped[0] != 2 || res.To != 2 {
+		t.Fatal(res, er)
+	}
+
+	var status sql.NullString
+	if er := db.QueryRow("SELECT status FROM migration_history WHERE version = 2").Scan(&status); er != nil || status.String != "skipped" {
+		t.Fatal(status, er)
+	}
+
+	st, er := s.Status(db)
+	if er != nil || !st[0].Applied || st[1].Applied || !st[1].Skipped {
+		t.Fatal(st, er)
+	}
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// TestMigration is a helper for tests focusing on a single migration. It
+// brings db up to version-1 by applying every migration with a lower
+// minVersion, then applies only the migrations registered with version,
+// leaving db at version for the test's assertions. Later migrations are not
+// run. Any failure is reported via t.Fatalf.
+func (s *Schema) TestMigration(t testing.TB, db *sql.DB, version int) {
+	t.Helper()
+	s = s.forDB(db)
+
+	migrations, er := s.collect()
+	if er != nil {
+		t.Fatalf("migrate: %v", er)
+	}
+
+	var before, target []migration
+	for _, m := range migrations {
+		switch {
+		case m.minVersion < version:
+			before = append(before, m)
+
+		case m.minVersion == version:
+			target = append(target, m)
+		}
+	}
+
+	if len(target) == 0 {
+		t.Fatalf("migrate: no migration registered with version %d", version)
+	}
+
+	ctx := context.Background()
+
+	er = s.session(ctx, db, func(conn DB) error {
+		if er := s.migrate(ctx, conn, before, version-1, &Result{}); er != nil {
+			return er
+		}
+
+		return s.migrate(ctx, conn, target, version, &Result{})
+	})
+	if er != nil {
+		t.Fatalf("migrate: applying migration %d: %v", version, er)
+	}
+}
+
+// SimulateAutoCommitDDL wraps d for tests of how migrations cope with an RDBMS
+// whose DDL isn't transactional, such as MySQL, on one whose DDL is, such as
+// SQLite. The wrapped Dialect reports that DDL isn't transactional, so Install
+// defaults to TxPerMigration exactly as on MySQL, and when a migration fails
+// its transaction is committed instead of rolled back, leaving behind whatever
+// the migration's earlier statements did (and the dirty flag, with
+// WithDirtyFlag) as if each had committed on its own. The version stamp isn't
+// advanced, so a re-run retries the migration, which is what WithIdempotentDDL
+// and AlreadyApplied are for. Note that the rewrites WithIdempotentDDL applies
+// are those of the generic dialect regardless of d, and that optional
+// interfaces such as TryLocker are hidden by the wrapper. It is not meant for
+// use against a real database.
+func SimulateAutoCommitDDL(d Dialect) Dialect {
+	if d == nil {
+		d = genericDialect{}
+	}
+
+	return autoCommitDialect{d}
+}
+
+type autoCommitDialect struct {
+	Dialect
+}
+
+func (autoCommitDialect) TransactionalDDL() bool {
+	return false
+}
+
+// autoCommits reports whether d was returned by SimulateAutoCommitDDL.
+func autoCommits(d Dialect) bool {
+	_, ok := d.(autoCommitDialect)
+	return ok
+}
@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fatalTB is a testing.TB that records the message of the Fatalf that stops
+// the goroutine it is used on.
+type fatalTB struct {
+	testing.TB
+	msg string
+}
+
+func (f *fatalTB) Helper() {}
+
+func (f *fatalTB) Fatalf(format string, args ...interface{}) {
+	f.msg = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func TestTestMigration(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE tm1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE tm2(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE tm2b(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE tm3(x INT)")
+	s.TestMigration(t, db, 2)
+	for _, table := range []string{"tm1", "tm2", "tm2b"} {
+		if _, er := db.Exec("SELECT * FROM " + table); er != nil {
+			t.Fatal(er)
+		}
+	}
+	if _, er := db.Exec("SELECT * FROM tm3"); er == nil {
+		t.Fatal("tm3 exists")
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+
+	s.UpdateSQL(3, "CREATE TABLE nope nope")
+	for version, want := range map[int]string{
+		4: "no migration registered with version 4",
+		3: "applying migration 3",
+	} {
+		tb := &fatalTB{TB: t}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.TestMigration(tb, openDB(t), version)
+		}()
+		<-done
+		if !strings.Contains(tb.msg, want) {
+			t.Fatal(version, tb.msg)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PrerequisiteError reports a migration whose declared prerequisite has neither
+// been applied nor been scheduled to run before it.
+type PrerequisiteError struct {
+	Version      int
+	Prerequisite int
+}
+
+func (e *PrerequisiteError) Error() string {
+	return fmt.Sprintf("migrate: migration %d requires migration %d, which is not applied or scheduled before it", e.Version, e.Prerequisite)
+}
+
+// Requires declares that the migration registered with minVersion depends on
+// the migrations registered with each of the prerequisite versions. Install
+// refuses to run a migration before its prerequisites, returning a
+// PrerequisiteError instead, and Validate checks that the registration order
+// respects every declaration.
+func (s *Schema) Requires(minVersion int, prerequisites ...int) {
+	if s.prereqs == nil {
+		s.prereqs = make(map[int][]int)
+	}
+
+	s.prereqs[minVersion] = append(s.prereqs[minVersion], prerequisites...)
+}
+
+// Validate checks the registered migrations, and the name of the history
+// table, for mistakes that would otherwise only surface while installing. It
+// needs no database.
+func (s *Schema) Validate() error {
+	if er := s.checkHistoryTable(); er != nil {
+		return er
+	}
+
+	if er := s.validateCheckpoint(s.migrations); er != nil {
+		return er
+	}
+
+	if er := validateNoTx(s.migrations); er != nil {
+		return er
+	}
+
+	if er := validateSeeds(s.migrations); er != nil {
+		return er
+	}
+
+	scheduled := make(map[int]bool)
+
+	for _, m := range s.migrations {
+		if er := s.checkPrerequisites(m.minVersion, 0, scheduled); er != nil {
+			return er
+		}
+
+		scheduled[m.minVersion] = true
+	}
+
+	for version := range s.prereqs {
+		if !scheduled[version] {
+			return fmt.Errorf("migrate: prerequisites declared for unregistered migration %d", version)
+		}
+	}
+
+	return nil
+}
+
+// checkPrerequisites returns a PrerequisiteError if any prerequisite of version
+// is newer than current (the database's version) and not in scheduled.
+func (s *Schema) checkPrerequisites(version, current int, scheduled map[int]bool) error {
+	for _, p := range s.prereqs[version] {
+		if p > current && !scheduled[p] {
+			return &PrerequisiteError{Version: version, Prerequisite: p}
+		}
+	}
+
+	return nil
+}
+
+// Versions returns the distinct minVersions of the registered migrations, in
+// ascending order.
+func (s *Schema) Versions() []int {
+	seen := make(map[int]bool)
+	var versions []int
+
+	for _, m := range s.migrations {
+		if !seen[m.minVersion] {
+			seen[m.minVersion] = true
+			versions = append(versions, m.minVersion)
+		}
+	}
+
+	sort.Ints(versions)
+	return versions
+}
+
+// Diff compares the migrations registered with a and b, which need no database.
+// It returns the versions of a's migrations that b lacks and those of b's that
+// a lacks, each distinct and in ascending order. Migrations are matched by
+// minVersion and name, so a version registered under different names in the
+// two schemas appears in both lists.
+func (a *Schema) Diff(b *Schema) ([]int, []int) {
+	return a.missingFrom(b), b.missingFrom(a)
+}
+
+// missingFrom returns the distinct minVersions of s's migrations that other has
+// no migration of the same minVersion and name for, in ascending order.
+func (s *Schema) missingFrom(other *Schema) []int {
+	type key struct {
+		version int
+		name    string
+	}
+
+	registered := make(map[key]bool)
+	for _, m := range other.migrations {
+		registered[key{m.minVersion, m.name}] = true
+	}
+
+	seen := make(map[int]bool)
+	var versions []int
+
+	for _, m := range s.migrations {
+		if !registered[key{m.minVersion, m.name}] && !seen[m.minVersion] {
+			seen[m.minVersion] = true
+			versions = append(versions, m.minVersion)
+		}
+	}
+
+	sort.Ints(versions)
+	return versions
+}
+
+// ErrMaxVersionExceedsKnown is returned by Install when maxVersion is greater
+// than the highest registered minVersion, which usually means the deployed
+// configuration doesn't match the code; stamping such a version would make the
+// database look newer than the binary that migrated it. See
+// AllowUnknownMaxVersion.
+var ErrMaxVersionExceedsKnown = errors.New("migrate: maxVersion exceeds the highest registered migration")
+
+// AllowUnknownMaxVersion lets Install stamp a maxVersion greater than the
+// highest registered minVersion instead of returning
+// ErrMaxVersionExceedsKnown.
+func AllowUnknownMaxVersion() Option {
+	return func(s *Schema) {
+		s.anyMaxVersion = true
+	}
+}
+
+func (s *Schema) checkMaxVersion(migrations []migration, maxVersion int) error {
+	if highest := s.latestVersion(migrations); maxVersion > highest && !s.anyMaxVersion {
+		return fmt.Errorf("%w: %d > %d", ErrMaxVersionExceedsKnown, maxVersion, highest)
+	}
+
+	return nil
+}
+
+// highestVersion returns the highest minVersion of migrations, or 0 if there are
+// none.
+func highestVersion(migrations []migration) int {
+	highest := 0
+	for _, m := range migrations {
+		if m.minVersion > highest {
+			highest = m.minVersion
+		}
+	}
+
+	return highest
+}
+
+// latestVersion is highestVersion, counting the checkpoint (see Checkpoint) as
+// well, which a fresh database can be installed to on its own.
+func (s *Schema) latestVersion(migrations []migration) int {
+	highest := highestVersion(migrations)
+	if s.checkpoint != nil && s.checkpoint.minVersion > highest {
+		highest = s.checkpoint.minVersion
+	}
+
+	return highest
+}
+
+// AssertLatest returns an error if the highest registered minVersion isn't
+// expected, to keep a hand-maintained "current version" constant in step with
+// the migrations. It needs no database.
+func (s *Schema) AssertLatest(expected int) error {
+	if highest := s.latestVersion(s.migrations); highest != expected {
+		return fmt.Errorf("migrate: latest migration is %d, expected %d", highest, expected)
+	}
+
+	return nil
+}
+
+// AssertNoPending returns an error listing the migrations that Install would
+// apply to db, if there are any. Like Schema.Plan it only reads from db.
+func (s *Schema) AssertNoPending(db *sql.DB) error {
+	migrations, er := s.collect()
+	if er != nil {
+		return er
+	}
+
+	plan, er := s.Plan(db, s.latestVersion(migrations))
+	if er != nil {
+		return er
+	}
+
+	if len(plan.Migrations) == 0 {
+		return nil
+	}
+
+	pending := make([]int, len(plan.Migrations))
+	for i, m := range plan.Migrations {
+		pending[i] = m.Version
+	}
+
+	return fmt.Errorf("migrate: database at version %d has pending migrations %v", plan.Current, pending)
+}
+
+// OrphanedVersionsError is returned by Schema.ValidateDB when the database
+// records versions as applied that no registered migration has.
+type OrphanedVersionsError struct {
+	Versions []int
+}
+
+func (e *OrphanedVersionsError) Error() string {
+	return fmt.Sprintf("migrate: database records versions %v as applied, but no migration is registered for them", e.Versions)
+}
+
+// ValidateDB runs Validate, then checks the versions the database records as
+// applied against the registered migrations, returning an
+// OrphanedVersionsError for any that are missing from the code (e.g. because
+// a migration file was deleted after it reached production). The recorded
+// versions are those in the history table (see WithHistory), except for
+// reverted ones, and, with WithVersionLog, the version log; a version seeded
+// via AdoptFrom or DetectExistingVersion that has no migration of its own is
+// reported too. Without either table there is nothing to check. It only reads
+// from db.
+func (s *Schema) ValidateDB(db *sql.DB) error {
+	s = s.forDB(db)
+	if er := s.Validate(); er != nil {
+		return er
+	}
+
+	migrations, er := s.collect()
+	if er != nil {
+		return er
+	}
+
+	registered := make(map[int]bool)
+	for _, m := range migrations {
+		registered[m.minVersion] = true
+	}
+
+	if s.checkpoint != nil {
+		registered[s.checkpoint.minVersion] = true
+	}
+
+	ctx := context.Background()
+	orphans := make(map[int]bool)
+
+	for _, table := range []string{s.historyTable(), versionLogTable} {
+		if table == versionLogTable && !s.versionLog {
+			continue
+		}
+
+		exists, er := s.getDialect().TableExists(ctx, db, table)
+		if er != nil {
+			return er
+		}
+
+		if !exists {
+			continue
+		}
+
+		// Reverted migrations are no longer applied.
+		query := "SELECT DISTINCT version FROM " + table
+		if table != versionLogTable && hasColumn(ctx, db, table, "status") {
+			query += " WHERE " + notReverted
+		}
+
+		rows, er := db.QueryContext(ctx, query)
+		if er != nil {
+			return er
+		}
+
+		for rows.Next() {
+			var version int
+			if er := rows.Scan(&version); er != nil {
+				rows.Close()
+				return er
+			}
+
+			if version != 0 && !registered[version] {
+				orphans[version] = true
+			}
+		}
+
+		rows.Close()
+		if er := rows.Err(); er != nil {
+			return er
+		}
+	}
+
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	versions := make([]int, 0, len(orphans))
+	for version := range orphans {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	return &OrphanedVersionsError{Versions: versions}
+}
+
+// OrderInversionError is returned by Schema.CheckHistoryOrder when the history
+// table shows migrations applied out of version order. Each pair holds the
+// higher version, applied first, and the lower version applied after it.
+type OrderInversionError struct {
+	Pairs [][2]int
+}
+
+func (e *OrderInversionError) Error() string {
+	pairs := make([]string, len(e.Pairs))
+	for i, p := range e.Pairs {
+		pairs[i] = fmt.Sprintf("%d before %d", p[0], p[1])
+	}
+
+	return "migrate: history shows migrations applied out of order: " + strings.Join(pairs, ", ")
+}
+
+// CheckHistoryOrder compares the order in which the history table (see
+// WithHistory) says migrations were applied with their version order, and
+// returns an OrderInversionError listing every pair of versions that was
+// applied the wrong way round, e.g. after a manual intervention. Migrations
+// recorded with the same applied_at are taken to be in version order, and
+// only the first application of each version counts. It only reads from db.
+func (s *Schema) CheckHistoryOrder(db *sql.DB) error {
+	if er := s.checkHistoryTable(); er != nil {
+		return er
+	}
+
+	rows, er := db.QueryContext(context.Background(), "SELECT version FROM "+s.historyTable()+" GROUP BY version ORDER BY MIN(applied_at), version")
+	if er != nil {
+		return er
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if er := rows.Scan(&version); er != nil {
+			return er
+		}
+
+		versions = append(versions, version)
+	}
+
+	if er := rows.Err(); er != nil {
+		return er
+	}
+
+	var pairs [][2]int
+	for i, earlier := range versions {
+		for _, later := range versions[i+1:] {
+			if earlier > later {
+				pairs = append(pairs, [2]int{earlier, later})
+			}
+		}
+	}
+
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	return &OrderInversionError{Pairs: pairs}
+}
@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateDB(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.UpdateSQL(1, "CREATE TABLE v1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE v2(x INT)")
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.ValidateDB(db); er != nil {
+		t.Fatal(er)
+	}
+
+	var trimmed Schema
+	trimmed.Configure(WithHistory())
+	trimmed.UpdateSQL(1, "CREATE TABLE v1(x INT)")
+	var oe *OrphanedVersionsError
+	if er := trimmed.ValidateDB(db); !errors.As(er, &oe) || len(oe.Versions) != 1 || oe.Versions[0] != 2 {
+		t.Fatal(er)
+	}
+}
+
+func TestValidateDBCheckpoint(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithHistory())
+	s.Checkpoint(5, func(v int, tx *sql.Tx) error { _, er := tx.Exec("CREATE TABLE vc(x INT)"); return er })
+	s.UpdateSQL(6, "CREATE TABLE vc6(x INT)")
+	if er := s.Install(db, 6); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.ValidateDB(db); er != nil {
+		t.Fatal(er)
+	}
+}
+
+func TestRequires(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE rq1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE rq2(x INT)")
+	s.Requires(2, 1)
+	if er := s.Validate(); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+
+	s.UpdateSQL(3, "CREATE TABLE rq3(x INT)")
+	s.UpdateSQL(4, "CREATE TABLE rq4(x INT)")
+	s.Requires(3, 4)
+	var pe *PrerequisiteError
+	if er := s.Validate(); !errors.As(er, &pe) || pe.Version != 3 || pe.Prerequisite != 4 {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 4); !errors.As(er, &pe) {
+		t.Fatal(er)
+	}
+	if v, _ := Version(db); v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var a, b Schema
+	a.UpdateSQL(1, "CREATE TABLE df1(x INT)")
+	a.UpdateNamed(2, "users", "", func(int, *sql.Tx) error { return nil })
+	a.UpdateSQL(3, "CREATE TABLE df3(x INT)")
+	a.UpdateSQL(3, "CREATE TABLE df3b(x INT)")
+	b.UpdateSQL(1, "CREATE TABLE df1(x INT)")
+	b.UpdateNamed(2, "accounts", "", func(int, *sql.Tx) error { return nil })
+	b.UpdateSQL(4, "CREATE TABLE df4(x INT)")
+
+	onlyA, onlyB := a.Diff(&b)
+	if len(onlyA) != 2 || onlyA[0] != 2 || onlyA[1] != 3 {
+		t.Fatal(onlyA)
+	}
+	if len(onlyB) != 2 || onlyB[0] != 2 || onlyB[1] != 4 {
+		t.Fatal(onlyB)
+	}
+	if onlyA, onlyB := a.Diff(&a); len(onlyA) != 0 || len(onlyB) != 0 {
+		t.Fatal(onlyA, onlyB)
+	}
+}
+
+func TestAssertNoPending(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE np1(x INT)")
+	s.UpdateSQL(2, "CREATE TABLE np2(x INT)")
+	s.UpdateSQL(3, "CREATE TABLE np3(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.AssertNoPending(db); er == nil || !strings.Contains(er.Error(), "version 1 has pending migrations [2 3]") {
+		t.Fatal(er)
+	}
+	if _, er := db.Exec("SELECT * FROM np2"); er == nil {
+		t.Fatal("np2 exists")
+	}
+
+	if er := s.Install(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.AssertNoPending(db); er != nil {
+		t.Fatal(er)
+	}
+}
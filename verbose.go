@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"fmt"
+	"log"
+)
+
+// WithVerbose makes Install log, to l, what it does with every registered
+// migration: whether it was applied, or why it was skipped (its minVersion was
+// not above the database's version, it was above maxVersion, or none of its
+// tags is active). It is meant for working out why an expected migration
+// didn't run.
+func WithVerbose(l *log.Logger) Option {
+	return func(s *Schema) {
+		s.verbose = l
+	}
+}
+
+// logMigration logs what happened to m if verbose logging is enabled.
+func (s *Schema) logMigration(m migration, format string, args ...interface{}) {
+	if s.verbose == nil {
+		return
+	}
+
+	label := fmt.Sprintf("migration %d", m.minVersion)
+	if m.name != "" {
+		label += " (" + m.name + ")"
+	}
+
+	if m.description != "" {
+		label += " [" + m.description + "]"
+	}
+
+	s.verbose.Printf("migrate: %s: %s", label, fmt.Sprintf(format, args...))
+}
+
+// pending reports whether m is due to run against a database at version, and
+// logs why not otherwise.
+func (s *Schema) pending(m migration, version, maxVersion int) bool {
+	switch {
+	case m.minVersion <= version:
+		s.logMigration(m, "skipped: minVersion %d <= database version %d", m.minVersion, version)
+		return false
+
+	case m.minVersion > maxVersion:
+		s.logMigration(m, "skipped: minVersion %d > maxVersion %d", m.minVersion, maxVersion)
+		return false
+	}
+
+	return true
+}
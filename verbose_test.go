@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestVerbose(t *testing.T) {
+	db := openDB(t)
+	var b strings.Builder
+	var s Schema
+	s.Configure(WithVerbose(log.New(&b, "", 0)), WithEnvironment("prod"))
+	s.UpdateSQL(1, "CREATE TABLE vb1(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if !strings.Contains(b.String(), "migrate: migration 1: applied in ") {
+		t.Fatal(b.String())
+	}
+
+	s.UpdateNamed(2, "fixtures", "", func(int, *sql.Tx) error { t.Fatal("ran"); return nil })
+	s.Tag(2, "dev")
+	s.UpdateSQL(3, "CREATE TABLE vb3(x INT)")
+	b.Reset()
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"migration 1: skipped: minVersion 1 <= database version 1",
+		"migration 2 (fixtures): skipped: none of its tags is active",
+		"migration 3: skipped: minVersion 3 > maxVersion 2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("%q not in %q", want, out)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// versionTable is the name of the table holding the schema version.
+const versionTable = "version"
+
+// ErrNoVersionTable is returned by Version and VersionContext when the database
+// has no version table, i.e. it has never been migrated.
+var ErrNoVersionTable = errors.New("migrate: version table does not exist")
+
+// Version returns the database's current schema version. Unlike Schema.Install
+// it never creates the version table, so it is safe to call with a read-only
+// role; if the table is missing, ErrNoVersionTable is returned.
+func Version(db DB) (int, error) {
+	return VersionContext(context.Background(), db)
+}
+
+// VersionContext is like Version but honours ctx, which makes it suitable for
+// health probes that must not hang on an unresponsive database.
+func VersionContext(ctx context.Context, db DB) (int, error) {
+	var version int
+
+	er := db.QueryRowContext(ctx, "SELECT version FROM "+versionTable).Scan(&version)
+	switch {
+	case er == sql.ErrNoRows:
+		return 0, nil
+
+	case IsUndefinedObject(er):
+		return 0, fmt.Errorf("%w: %v", ErrNoVersionTable, er)
+
+	case er != nil:
+		return 0, er
+	}
+
+	return version, nil
+}
+
+// newVersion returns the version a missing version table would be seeded
+// with (see initialVersion), or 0 if the table exists. It only reads from db.
+func (s *Schema) newVersion(ctx context.Context, db DB) (int, error) {
+	exists, er := s.getDialect().TableExists(ctx, db, s.stateTable())
+	if er != nil || exists {
+		return 0, er
+	}
+
+	return s.initialVersion(ctx, db)
+}
+
+// getDbVersion returns the database's version, first creating the version
+// table and seeding it with initial if it doesn't exist yet.
+func (s *Schema) getDbVersion(ctx context.Context, db DB, initial int) (int, error) {
+	if s.versionLog {
+		return s.getLogVersion(ctx, db, initial)
+	}
+
+	d := s.getDialect()
+
+	exists, er := d.TableExists(ctx, db, versionTable)
+	if er != nil {
+		return 0, er
+	}
+
+	if !exists {
+		create := s.createSQL
+		if create == "" {
+			create = d.CreateVersionTable(versionTable)
+		}
+
+		if _, er = db.ExecContext(ctx, create); er != nil {
+			return 0, er
+		}
+
+		if er = s.seedVersion(ctx, db, initial); er != nil {
+			return 0, er
+		}
+
+		return initial, nil
+	}
+
+	rows, er := db.QueryContext(ctx, "SELECT version FROM "+versionTable)
+	if er != nil {
+		return 0, er
+	}
+
+	if !rows.Next() {
+		rows.Close()
+
+		if er = s.seedVersion(ctx, db, 0); er != nil {
+			return 0, er
+		}
+
+		return 0, nil
+	}
+
+	var version int
+
+	if er = rows.Scan(&version); er != nil {
+		rows.Close()
+		return 0, er
+	}
+
+	rows.Close()
+	return version, nil
+}
+
+// seedVersion inserts the version row, with the given version. Only the version
+// column, and those set with WithVersionColumns, are given values; any other
+// columns of the table must be nullable or have defaults.
+func (s *Schema) seedVersion(ctx context.Context, q Querier, version int) error {
+	if s.seedSQL != "" {
+		_, er := q.ExecContext(ctx, s.seedSQL, version)
+		return er
+	}
+
+	d := s.getDialect()
+	columns := make([]string, 0, len(s.versionColumns))
+	for column := range s.versionColumns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	names := "version"
+	placeholders := d.Placeholder(1)
+	args := []interface{}{version}
+
+	for _, column := range columns {
+		args = append(args, s.versionColumns[column])
+		names += ", " + column
+		placeholders += ", " + d.Placeholder(len(args))
+	}
+
+	_, er := q.ExecContext(ctx, "INSERT INTO "+versionTable+"("+names+") VALUES("+placeholders+")", args...)
+	return er
+}
+
+// readVersion reads the version row, treating a missing row as version 0 (it
+// is re-inserted when the version is next stamped).
+func (s *Schema) readVersion(ctx context.Context, q Querier) (int, error) {
+	if s.versionLog {
+		return readLogVersion(ctx, q)
+	}
+
+	var version int
+
+	er := q.QueryRowContext(ctx, "SELECT version FROM "+versionTable).Scan(&version)
+	if er == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return version, er
+}
+
+// ErrVersionConflict is returned when the version row was changed by someone
+// else while a migration was in progress.
+var ErrVersionConflict = errors.New("migrate: version changed concurrently")
+
+// setDbVersion stamps the version row, changing it from expected (the version
+// read when the transaction began) to version. The UPDATE is conditional on the
+// row still holding expected, so if another process got there first nothing is
+// overwritten: the row is re-read, and unless it already holds version,
+// ErrVersionConflict is returned. The re-read also covers drivers (MySQL) that
+// report zero affected rows for an UPDATE that doesn't change the value, and a
+// row that has gone missing since the table was bootstrapped, which is
+// re-inserted rather than silently leaving the version unrecorded.
+func (s *Schema) setDbVersion(ctx context.Context, tx *sql.Tx, expected, version int) error {
+	if s.versionLog {
+		return s.setLogVersion(ctx, tx, expected, version)
+	}
+
+	d := s.getDialect()
+	q := s.executor(tx)
+
+	res, er := q.ExecContext(ctx, "UPDATE "+versionTable+" SET version = "+d.Placeholder(1)+" WHERE version = "+d.Placeholder(2), version, expected)
+	if er != nil {
+		return er
+	}
+
+	n, er := res.RowsAffected()
+	if er != nil {
+		return er
+	}
+
+	if n > 0 {
+		return s.clearState(ctx, tx)
+	}
+
+	var current int
+	er = q.QueryRowContext(ctx, "SELECT version FROM "+versionTable).Scan(&current)
+	switch {
+	case er == sql.ErrNoRows:
+		return s.seedVersion(ctx, q, version)
+
+	case er != nil:
+		return er
+
+	case current != version:
+		return fmt.Errorf("%w: expected version %d, found %d", ErrVersionConflict, expected, current)
+	}
+
+	return s.clearState(ctx, tx)
+}
+
+// clearState resets the dirty flag and plan hash of a newly stamped version.
+func (s *Schema) clearState(ctx context.Context, tx *sql.Tx) error {
+	if er := s.setDirty(ctx, tx, false); er != nil {
+		return er
+	}
+
+	return s.setPlanHash(ctx, tx, nil, 0)
+}
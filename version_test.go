@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestMissingVersionRow(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Update(1, func(v int, tx *sql.Tx) error {
+		_, er := tx.Exec("DELETE FROM version")
+		return er
+	})
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if v, er := Version(db); er != nil || v != 1 {
+		t.Fatal(v, er)
+	}
+	res, er := s.InstallResult(db, 1)
+	if er != nil || !res.UpToDate {
+		t.Fatal(res, er)
+	}
+}
+
+func TestVersionContext(t *testing.T) {
+	db := openDB(t)
+	if _, er := VersionContext(context.Background(), db); !errors.Is(er, ErrNoVersionTable) {
+		t.Fatal(er)
+	}
+
+	var s Schema
+	s.UpdateSQL(1, "CREATE TABLE vx1(x INT)")
+	if er := s.Install(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	if v, er := VersionContext(context.Background(), db); er != nil || v != 1 {
+		t.Fatal(v, er)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, er := VersionContext(ctx, db); !errors.Is(er, context.Canceled) {
+		t.Fatal(er)
+	}
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// versionLogTable is the name of the table WithVersionLog keeps the version in.
+const versionLogTable = "schema_log"
+
+// WithVersionLog replaces the single-row version table with an append-only
+// log, schema_log(version INT, applied_at TIMESTAMP), whose current version
+// is MAX(version). Stamping a new version inserts a row rather than updating
+// one, which gives a natural record of every version the database has been
+// at; rolling back deletes the rows above the target. The tradeoff is that the
+// table grows by a row per Install that changes the version; old rows below
+// the current version may be pruned at will. The package-level Version
+// function still reads the version table, and this mode can't be combined
+// with WithDirtyFlag.
+func WithVersionLog() Option {
+	return func(s *Schema) {
+		s.versionLog = true
+	}
+}
+
+// stateTable returns the name of the table holding the database's version.
+func (s *Schema) stateTable() string {
+	if s.versionLog {
+		return versionLogTable
+	}
+
+	return versionTable
+}
+
+// getLogVersion is the WithVersionLog counterpart of getDbVersion.
+func (s *Schema) getLogVersion(ctx context.Context, db DB, initial int) (int, error) {
+	d := s.getDialect()
+
+	exists, er := d.TableExists(ctx, db, versionLogTable)
+	if er != nil {
+		return 0, er
+	}
+
+	if exists {
+		return readLogVersion(ctx, db)
+	}
+
+	if _, er := db.ExecContext(ctx, "CREATE TABLE "+versionLogTable+" (version INT NOT NULL, applied_at "+s.timestampType()+" NOT NULL)"); er != nil {
+		return 0, er
+	}
+
+	if initial != 0 {
+		if er := s.appendVersion(ctx, db, initial); er != nil {
+			return 0, er
+		}
+	}
+
+	return initial, nil
+}
+
+func readLogVersion(ctx context.Context, q Querier) (int, error) {
+	var version sql.NullInt64
+	er := q.QueryRowContext(ctx, "SELECT MAX(version) FROM "+versionLogTable).Scan(&version)
+	return int(version.Int64), er
+}
+
+func (s *Schema) appendVersion(ctx context.Context, q Querier, version int) error {
+	d := s.getDialect()
+	_, er := q.ExecContext(ctx, "INSERT INTO "+versionLogTable+"(version, applied_at) VALUES("+d.Placeholder(1)+", "+d.Placeholder(2)+")", version, s.now())
+	return er
+}
+
+// setLogVersion is the WithVersionLog counterpart of setDbVersion.
+func (s *Schema) setLogVersion(ctx context.Context, tx *sql.Tx, expected, version int) error {
+	q := s.executor(tx)
+
+	current, er := readLogVersion(ctx, q)
+	switch {
+	case er != nil:
+		return er
+
+	case current == version:
+		return nil
+
+	case current != expected:
+		return fmt.Errorf("%w: expected version %d, found %d", ErrVersionConflict, expected, current)
+
+	case version < current:
+		if _, er := q.ExecContext(ctx, "DELETE FROM "+versionLogTable+" WHERE version > "+s.getDialect().Placeholder(1), version); er != nil {
+			return er
+		}
+
+		if current, er = readLogVersion(ctx, q); er != nil || current == version {
+			return er
+		}
+	}
+
+	return s.appendVersion(ctx, q, version)
+}
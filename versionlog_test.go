@@ -0,0 +1,34 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestVersionLog(t *testing.T) {
+	db := openDB(t)
+	var s Schema
+	s.Configure(WithVersionLog())
+	for i := 1; i <= 3; i++ {
+		s.Update(i, func(v int, tx *sql.Tx) error { return nil })
+		s.Down(i, func(v int, tx *sql.Tx) error { return nil })
+	}
+	if er := s.Install(db, 2); er != nil {
+		t.Fatal(er)
+	}
+	if er := s.Install(db, 3); er != nil {
+		t.Fatal(er)
+	}
+	var n, max int
+	db.QueryRow("SELECT COUNT(*), MAX(version) FROM schema_log").Scan(&n, &max)
+	if n != 2 || max != 3 {
+		t.Fatal(n, max)
+	}
+	if er := s.Rollback(db, 1); er != nil {
+		t.Fatal(er)
+	}
+	db.QueryRow("SELECT COUNT(*), MAX(version) FROM schema_log").Scan(&n, &max)
+	if n != 1 || max != 1 {
+		t.Fatal(n, max)
+	}
+}